@@ -2,22 +2,88 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"os"
+	"strings"
 
+	"github.com/HakanSunay/gohil/eval"
+	"github.com/HakanSunay/gohil/interpreter"
 	"github.com/HakanSunay/gohil/logger"
 	"github.com/HakanSunay/gohil/shell"
 )
 
 var ctx = context.Background()
 
+var (
+	backendFlag  = flag.String("backend", string(interpreter.BackendEval), "execution backend: eval (tree-walking) or vm (bytecode)")
+	timeoutFlag  = flag.Duration("timeout", 0, "maximum duration of every evaluation, 0 means unlimited")
+	maxStepsFlag = flag.Int("max-steps", 0, "maximum number of steps of every evaluation, 0 means unlimited")
+	maxDepthFlag = flag.Int("max-depth", eval.DefaultMaxDepth, "maximum depth of the call stack")
+
+	traceFlag          = flag.Bool("trace", false, "log every evaluated node and function call at debug level (eval backend only)")
+	traceFunctionsFlag = flag.String("trace-functions", "", "comma separated names of the functions whose calls are traced, all by default")
+
+	logConfigFlag = flag.String("log-config", "", "JSON logging config file (env "+logger.EnvConfig+")")
+	logFlags      logger.Config
+)
+
 func init() {
-	logger.InitGlobalLogging()
-	ctx = logger.PutLoggerInContext(ctx)
+	logFlags.RegisterFlags(flag.CommandLine)
 }
 
 func main() {
+	flag.Parse()
+
+	// flags take precedence over the environment, which takes precedence over the config file
+	logConfig, err := logger.LoadConfig(*logConfigFlag, logFlags)
+	if err == nil {
+		err = logger.Init(logConfig)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(shell.ExitUsageError)
+	}
+	ctx = logger.PutLoggerInContext(ctx)
+
 	log := logger.GetFromContext(ctx)
 	log.Infof("Starting gohil...")
-	shell.Start(ctx, os.Stdin, os.Stdout)
+
+	backend, err := interpreter.ParseBackend(*backendFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(shell.ExitUsageError)
+	}
+	log.Infof("Using the %s backend", backend)
+
+	opts := []interpreter.Option{
+		interpreter.WithBackend(backend),
+		interpreter.WithTimeout(*timeoutFlag),
+		interpreter.WithLimits(eval.Limits{MaxSteps: *maxStepsFlag, MaxDepth: *maxDepthFlag}),
+	}
+	if *traceFlag {
+		opts = append(opts, interpreter.WithTrace(eval.Trace{Functions: splitNames(*traceFunctionsFlag)}))
+	}
+
+	// gohil [flags] path/to/script.ghl [args...] runs the script instead of the shell
+	if flag.NArg() > 0 {
+		code := shell.RunScript(ctx, flag.Arg(0), flag.Args()[1:], os.Stdout, os.Stderr, opts...)
+		log.Infof("Terminating gohil with exit code %d...", code)
+		os.Exit(code)
+	}
+
+	shell.Start(ctx, os.Stdin, os.Stdout, os.Stderr, opts...)
 	log.Infof("Terminating gohil...")
 }
+
+// splitNames splits a comma separated list of names, ignoring the empty ones
+func splitNames(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
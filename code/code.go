@@ -0,0 +1,309 @@
+package code
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/HakanSunay/gohil/token"
+)
+
+// Instructions is a sequence of encoded instructions.
+// Every instruction consists of an Opcode (1 byte) followed by its operands (big endian).
+type Instructions []byte
+
+// String disassembles the instructions in a human readable form, one instruction per line
+// E.g:
+//
+//	0000 OpConstant 0
+//	0003 OpConstant 1
+//	0006 OpAdd
+func (ins Instructions) String() string {
+	var builder strings.Builder
+
+	for i := 0; i < len(ins); {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&builder, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&builder, "%04d %s\n", i, formatInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return builder.String()
+}
+
+func formatInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d", len(operands), len(def.OperandWidths))
+	}
+
+	parts := []string{def.Name}
+	for _, operand := range operands {
+		parts = append(parts, fmt.Sprintf("%d", operand))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Opcode identifies the operation of an instruction
+type Opcode byte
+
+const (
+	// OpConstant pushes the constant with the given index
+	OpConstant Opcode = iota
+	// OpPop pops the top of the stack, it terminates expression statements
+	OpPop
+
+	// Infix operators, they pop the right and the left operand and push the result
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpGreaterEqual
+	OpLessEqual
+
+	// Prefix operators, they pop the operand and push the result
+	OpMinus
+	OpBang
+
+	// Literals that do not need the constant pool
+	OpTrue
+	OpFalse
+	OpNull
+
+	// Jumps to the given instruction offset, OpJumpNotTruthy pops the condition first
+	OpJump
+	OpJumpNotTruthy
+	// OpJumpFalsyOrPop and OpJumpTruthyOrPop implement && and ||,
+	// they keep the left operand when they jump and pop it otherwise
+	OpJumpFalsyOrPop
+	OpJumpTruthyOrPop
+
+	// OpIterator pops the iterable of a for loop and pushes an iterator over its values
+	OpIterator
+	// OpNext pushes the next value of the iterator on the top of the stack,
+	// it jumps to the given offset when there are none left
+	OpNext
+	// OpEndLoop pops the given number of values that a loop kept on the stack (its iterator),
+	// loops are statements and have no value
+	OpEndLoop
+
+	// Globals live in the environment, the operand is the constant index of their name
+	OpGetGlobal
+	OpSetGlobal
+	// OpSetConstGlobal binds a global constant, which cannot be changed
+	OpSetConstGlobal
+	// The assignments keep the assigned value on the stack, because they are expressions
+	OpAssignGlobal
+
+	// Locals live on the stack of the current frame, the operand is their index
+	OpGetLocal
+	OpSetLocal
+	OpAssignLocal
+	// OpClearLocals clears the given number of locals starting at the given index, at the start of an iteration
+	OpClearLocals
+
+	// OpGetFree pushes a variable captured by the current closure
+	OpGetFree
+	OpAssignFree
+	// OpCaptureLocal and OpCaptureFree push the variable that a closure captures, rather than its value,
+	// so that an assignment by the closure or by its enclosing function is seen by both
+	OpCaptureLocal
+	OpCaptureFree
+	// OpCurrentClosure pushes the closure that is being executed, used for recursion
+	OpCurrentClosure
+
+	// OpArray and OpHash build a collection from the given number of stack elements
+	OpArray
+	OpHash
+	// OpIndex pops the index and the indexed object and pushes the element
+	OpIndex
+	// OpSetIndex pops the value, the index and the indexed object, replaces the element with the value
+	// and pushes it. A non-zero operand is the infix instruction of a compound assignment,
+	// which is applied to the element and the value first.
+	OpSetIndex
+
+	// OpCall calls the function below the given number of arguments
+	OpCall
+	// OpReturnValue returns the top of the stack from the current function
+	OpReturnValue
+	// OpReturn returns null from the current function
+	OpReturn
+
+	// OpClosure creates a closure from the function constant and the given number of free variables
+	OpClosure
+
+	// OpFail fails with the message in the string constant. The compiler emits it for the errors
+	// that it finds in the code, which the evaluator reports only once it runs that code.
+	OpFail
+)
+
+// Definition describes an Opcode, its name and the width of every operand in bytes
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpJump:            {"OpJump", []int{2}},
+	OpJumpNotTruthy:   {"OpJumpNotTruthy", []int{2}},
+	OpJumpFalsyOrPop:  {"OpJumpFalsyOrPop", []int{2}},
+	OpJumpTruthyOrPop: {"OpJumpTruthyOrPop", []int{2}},
+
+	OpIterator: {"OpIterator", []int{}},
+	OpNext:     {"OpNext", []int{2}},
+	OpEndLoop:  {"OpEndLoop", []int{1}},
+
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpSetConstGlobal: {"OpSetConstGlobal", []int{2}},
+	OpAssignGlobal:   {"OpAssignGlobal", []int{2}},
+
+	OpGetLocal:    {"OpGetLocal", []int{1}},
+	OpSetLocal:    {"OpSetLocal", []int{1}},
+	OpAssignLocal: {"OpAssignLocal", []int{1}},
+	OpClearLocals: {"OpClearLocals", []int{1, 1}},
+
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpAssignFree:     {"OpAssignFree", []int{1}},
+	OpCaptureLocal:   {"OpCaptureLocal", []int{1}},
+	OpCaptureFree:    {"OpCaptureFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+
+	OpArray:    {"OpArray", []int{2}},
+	OpHash:     {"OpHash", []int{2}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpSetIndex: {"OpSetIndex", []int{1}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+
+	OpClosure: {"OpClosure", []int{2, 1}},
+
+	OpFail: {"OpFail", []int{2}},
+}
+
+// Lookup returns the definition of the given opcode
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make encodes an instruction from the opcode and its operands
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, width := range def.OperandWidths {
+		length += width
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, operand := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(operand))
+		case 1:
+			instruction[offset] = byte(operand)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction,
+// it returns them together with the number of bytes that were read
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+// ReadUint16 decodes a 2 byte operand
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// ReadUint8 decodes a 1 byte operand
+func ReadUint8(ins Instructions) uint8 {
+	return ins[0]
+}
+
+// SourceMap maps instruction offsets to positions in the source text.
+// Entries are sorted by offset, every entry is valid until the offset of the next one.
+type SourceMap []SourceMapEntry
+
+// SourceMapEntry marks the offset of the first instruction that was compiled from the node at Pos
+type SourceMapEntry struct {
+	Offset int
+	Pos    token.Position
+}
+
+// Lookup returns the source position of the instruction at the given offset
+func (sm SourceMap) Lookup(offset int) token.Position {
+	// the first entry that starts after the offset, the one before it contains the offset
+	i := sort.Search(len(sm), func(i int) bool { return sm[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+
+	return sm[i-1].Pos
+}
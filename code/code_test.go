@@ -0,0 +1,74 @@
+package code
+
+import (
+	"testing"
+
+	"github.com/HakanSunay/gohil/token"
+)
+
+func TestMakeAndReadOperands(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+		if string(instruction) != string(tt.expected) {
+			t.Errorf("expected %v, but got %v", tt.expected, instruction)
+			continue
+		}
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %v", err)
+		}
+
+		operands, read := ReadOperands(def, instruction[1:])
+		if read != len(tt.expected)-1 {
+			t.Errorf("expected to read %d bytes, but read %d", len(tt.expected)-1, read)
+		}
+		for i, operand := range tt.operands {
+			if operands[i] != operand {
+				t.Errorf("expected operand %d to be %d, but got %d", i, operand, operands[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	var ins Instructions
+	ins = append(ins, Make(OpAdd)...)
+	ins = append(ins, Make(OpGetLocal, 1)...)
+	ins = append(ins, Make(OpConstant, 65535)...)
+	ins = append(ins, Make(OpClosure, 2, 1)...)
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 65535
+0006 OpClosure 2 1
+`
+	if ins.String() != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, ins.String())
+	}
+}
+
+func TestSourceMapLookup(t *testing.T) {
+	sourceMap := SourceMap{
+		{Offset: 0, Pos: token.Position{Line: 1, Column: 1}},
+		{Offset: 3, Pos: token.Position{Line: 2, Column: 5}},
+	}
+
+	tests := map[int]string{0: "1:1", 2: "1:1", 3: "2:5", 10: "2:5"}
+	for offset, expected := range tests {
+		if pos := sourceMap.Lookup(offset); pos.String() != expected {
+			t.Errorf("expected offset %d at %s, but got %s", offset, expected, pos)
+		}
+	}
+}
@@ -0,0 +1,753 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/HakanSunay/gohil/code"
+	"github.com/HakanSunay/gohil/object"
+	"github.com/HakanSunay/gohil/syntaxtree"
+	"github.com/HakanSunay/gohil/token"
+)
+
+// jumpPlaceholder is the operand of jumps that are emitted before their target is known
+const jumpPlaceholder = 9999
+
+// infixOpcodes maps the infix operators to the instructions that implement them
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	">=": code.OpGreaterEqual,
+	"<=": code.OpLessEqual,
+}
+
+// logicalJumps maps the short-circuiting operators to the jumps that skip their right operand
+var logicalJumps = map[string]code.Opcode{
+	"&&": code.OpJumpFalsyOrPop,
+	"||": code.OpJumpTruthyOrPop,
+}
+
+// prefixOpcodes maps the prefix operators to the instructions that implement them
+var prefixOpcodes = map[string]code.Opcode{
+	"!": code.OpBang,
+	"-": code.OpMinus,
+}
+
+// Bytecode is the result of a compilation, it is what the virtual machine executes
+type Bytecode struct {
+	Instructions code.Instructions
+	SourceMap    code.SourceMap
+	Constants    []object.Object
+	// NumLocals is the number of local slots the program needs for the locals of its blocks
+	NumLocals int
+	// LocalNames are the names of the local slots, see object.CompiledFunction
+	LocalNames []string
+}
+
+// EmittedInstruction remembers an instruction that was already emitted
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope holds the instructions of the function that is being compiled
+type CompilationScope struct {
+	instructions code.Instructions
+	sourceMap    code.SourceMap
+
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+
+	// loops are the loops of the function that are being compiled, the innermost one is the last
+	loops []*loop
+	// operands is the number of values that wait on the stack for the instruction that uses them,
+	// while the rest of the operands of that instruction are compiled
+	operands int
+}
+
+// loop is a loop that is being compiled, continue jumps to its start
+// and break jumps to its end, which is only known once the loop is compiled.
+// The operands of the function when the loop started are still on the stack after it,
+// break and continue can be part of an expression and pop the ones above them.
+type loop struct {
+	start    int
+	breaks   []int
+	operands int
+}
+
+// Compiler translates syntax trees to bytecode
+type Compiler struct {
+	constants []object.Object
+	// names holds the constant index of the name of every global, so that names are not duplicated
+	names map[string]int
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// pos is the position of the node that is being compiled, it ends up in the source map
+	pos token.Position
+}
+
+// NewCompiler is the constructor for the Compiler type
+func NewCompiler() *Compiler {
+	return &Compiler{
+		names:       make(map[string]int),
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{{}},
+	}
+}
+
+// Compile compiles the node and all of its children
+func (c *Compiler) Compile(node syntaxtree.Node) error {
+	previous := c.pos
+	c.pos = node.Pos()
+	defer func() { c.pos = previous }()
+
+	switch node := node.(type) {
+	// Statements:
+	case *syntaxtree.Program:
+		for _, stmt := range node.Statements {
+			if err := c.Compile(stmt); err != nil {
+				return err
+			}
+		}
+	case *syntaxtree.ExpressionStmt:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *syntaxtree.BlockStmt:
+		for _, stmt := range node.Statements {
+			if err := c.Compile(stmt); err != nil {
+				return err
+			}
+		}
+	case *syntaxtree.LetStmt:
+		// the value is compiled first, so that it still refers to the previous binding of the name
+		if err := c.compileNamedValue(node.Name.Value, node.Value); err != nil {
+			return err
+		}
+		if c.symbolTable.isLocalConstant(node.Name.Value) {
+			c.fail("cannot redeclare constant %s", node.Name.Value)
+		} else if node.Constant() {
+			c.storeConstant(c.symbolTable.DefineConstant(node.Name.Value))
+		} else {
+			c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+		}
+	case *syntaxtree.WhileStmt:
+		return c.compileWhileStatement(node)
+	case *syntaxtree.ForStmt:
+		return c.compileForStatement(node)
+	case *syntaxtree.BranchStmt:
+		loops := c.scopes[c.scopeIndex].loops
+		if len(loops) == 0 {
+			return fmt.Errorf("%s is not in a loop", node.Token.Literal)
+		}
+
+		current := loops[len(loops)-1]
+		for i := current.operands; i < c.scopes[c.scopeIndex].operands; i++ {
+			c.emit(code.OpPop)
+		}
+		if node.Token.Type == token.Break {
+			current.breaks = append(current.breaks, c.emit(code.OpJump, jumpPlaceholder))
+		} else {
+			c.emit(code.OpJump, current.start)
+		}
+	case *syntaxtree.ReturnStmt:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	// Expressions:
+	case *syntaxtree.Identifier:
+		c.loadSymbol(c.symbolTable.Resolve(node.Value))
+	case *syntaxtree.IntegerLiteral:
+		if node.Big != nil {
+			c.emit(code.OpConstant, c.addConstant(&object.BigInteger{Value: node.Big}))
+		} else {
+			c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+		}
+	case *syntaxtree.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+	case *syntaxtree.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *syntaxtree.BooleanLiteral:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *syntaxtree.PrefixExpr:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := prefixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+	case *syntaxtree.InfixExpr:
+		if jump, ok := logicalJumps[node.Operator]; ok {
+			if err := c.Compile(node.Left); err != nil {
+				return err
+			}
+			return c.compileLogicalExpression(jump, node)
+		}
+		if err := c.compileOperand(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+		c.useOperands(1)
+	case *syntaxtree.AssignExpr:
+		return c.compileAssignExpression(node)
+	case *syntaxtree.IfExpr:
+		return c.compileIfExpression(node)
+	case *syntaxtree.FunctionLiteral:
+		return c.compileFunction("", node)
+	case *syntaxtree.CallExpr:
+		if err := c.compileOperand(node.Function); err != nil {
+			return err
+		}
+		for _, arg := range node.Arguments {
+			if err := c.compileOperand(arg); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+		c.useOperands(len(node.Arguments) + 1)
+	case *syntaxtree.ArrayLiteral:
+		for _, element := range node.Elements {
+			if err := c.compileOperand(element); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+		c.useOperands(len(node.Elements))
+	case *syntaxtree.HashLiteral:
+		return c.compileHashLiteral(node)
+	case *syntaxtree.IndexExpression:
+		if err := c.compileOperand(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+		c.useOperands(1)
+	default:
+		return fmt.Errorf("unable to compile %T", node)
+	}
+
+	return nil
+}
+
+// Bytecode returns the result of the compilation so far
+func (c *Compiler) Bytecode() *Bytecode {
+	// every function shares the constants of the program, so that it can still be called later on
+	for _, constant := range c.constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			fn.Constants = c.constants
+		}
+	}
+
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		Constants:    c.constants,
+		NumLocals:    c.symbolTable.NumDefinitions(),
+		LocalNames:   c.symbolTable.LocalNames(),
+	}
+}
+
+// compileNamedValue compiles the value that is bound to name,
+// functions get to know their name, so that they can call themselves
+func (c *Compiler) compileNamedValue(name string, value syntaxtree.Expr) error {
+	if fn, ok := value.(*syntaxtree.FunctionLiteral); ok {
+		previous := c.pos
+		c.pos = fn.Pos()
+		defer func() { c.pos = previous }()
+
+		return c.compileFunction(name, fn)
+	}
+
+	return c.Compile(value)
+}
+
+func (c *Compiler) compileIfExpression(node *syntaxtree.IfExpr) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, jumpPlaceholder)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jump := c.emit(code.OpJump, jumpPlaceholder)
+	c.changeOperand(jumpNotTruthy, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jump, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *syntaxtree.WhileStmt) error {
+	start := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpNotTruthy, jumpPlaceholder)
+
+	if err := c.compileLoopBody(start, exit, nil, node.Body); err != nil {
+		return err
+	}
+
+	c.emit(code.OpEndLoop, 0)
+	return nil
+}
+
+func (c *Compiler) compileForStatement(node *syntaxtree.ForStmt) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIterator)
+
+	start := c.emit(code.OpNext, jumpPlaceholder)
+	if err := c.compileLoopBody(start, start, node.Variable, node.Body); err != nil {
+		return err
+	}
+
+	// the iterator is still on the stack
+	c.emit(code.OpEndLoop, 1)
+	return nil
+}
+
+// compileLoopBody compiles the body of a loop that starts at start, followed by the jump back to it.
+// The exit jump and the break statements are patched to jump right after the body.
+// Every iteration has its own scope, the variable of a for loop is defined in it, see eval.
+func (c *Compiler) compileLoopBody(start int, exit int, variable *syntaxtree.Identifier, body *syntaxtree.BlockStmt) error {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	c.symbolTable.Declare(declaredNames(body))
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	// the locals of the previous iteration are cleared, the closures that captured them keep them
+	first := c.symbolTable.NumDefinitions()
+	clear := c.emit(code.OpClearLocals, first, 0)
+	if variable != nil {
+		c.storeSymbol(c.symbolTable.Define(variable.Value))
+	}
+
+	scope := &c.scopes[c.scopeIndex]
+	current := &loop{start: start, operands: scope.operands}
+	scope.loops = append(scope.loops, current)
+
+	if err := c.Compile(body); err != nil {
+		return err
+	}
+	scope.loops = scope.loops[:len(scope.loops)-1]
+	c.replaceInstruction(clear, code.Make(code.OpClearLocals, first, c.symbolTable.NumDefinitions()-first))
+
+	c.emit(code.OpJump, start)
+
+	end := len(c.currentInstructions())
+	c.changeOperand(exit, end)
+	for _, position := range current.breaks {
+		c.changeOperand(position, end)
+	}
+
+	return nil
+}
+
+// compileLogicalExpression compiles the right operand of && or ||, the left one is already on the stack.
+// The jump leaves the left operand as the result when it decides the result, see eval.
+func (c *Compiler) compileLogicalExpression(jump code.Opcode, node *syntaxtree.InfixExpr) error {
+	position := c.emit(jump, jumpPlaceholder)
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	c.changeOperand(position, len(c.currentInstructions()))
+	return nil
+}
+
+// compileBlockValue compiles a block that leaves its value on the stack,
+// which is the value of its last expression statement or null
+func (c *Compiler) compileBlockValue(block *syntaxtree.BlockStmt) error {
+	start := len(c.currentInstructions())
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if len(c.currentInstructions()) > start && c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunction(name string, node *syntaxtree.FunctionLiteral) error {
+	c.enterScope()
+
+	if name != "" {
+		c.symbolTable.DefineFunctionName(name)
+	}
+	for _, param := range node.Parameters {
+		c.symbolTable.Define(param.Value)
+	}
+	c.symbolTable.Declare(declaredNames(node.Body))
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	localNames := c.symbolTable.LocalNames()
+	scope := c.leaveScope()
+
+	// the captured variables are pushed by the enclosing function, right before the closure is created
+	for _, symbol := range freeSymbols {
+		c.captureSymbol(symbol)
+	}
+
+	fn := &object.CompiledFunction{
+		Instructions:  scope.instructions,
+		SourceMap:     scope.sourceMap,
+		NumLocals:     numLocals,
+		LocalNames:    localNames,
+		NumParameters: len(node.Parameters),
+		Name:          name,
+		Parameters:    node.Parameters,
+		Body:          node.Body,
+	}
+	c.emit(code.OpClosure, c.addConstant(fn), len(freeSymbols))
+
+	return nil
+}
+
+// compileAssignExpression compiles an assignment, which leaves the assigned value on the stack.
+// The target is compiled before the value, see eval.
+func (c *Compiler) compileAssignExpression(node *syntaxtree.AssignExpr) error {
+	infix := code.Opcode(0)
+	if operator := node.InfixOperator(); operator != "" {
+		op, ok := infixOpcodes[operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		infix = op
+	}
+
+	switch target := node.Target.(type) {
+	case *syntaxtree.Identifier:
+		symbol := c.symbolTable.ResolveAssignment(target.Value)
+		if infix != 0 {
+			c.loadSymbol(symbol)
+			c.scopes[c.scopeIndex].operands++
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if infix != 0 {
+			c.emit(infix)
+			c.useOperands(1)
+		}
+		if symbol.Constant {
+			c.fail("cannot assign to constant %s", target.Value)
+		} else {
+			c.assignSymbol(symbol)
+		}
+	case *syntaxtree.IndexExpression:
+		if err := c.compileOperand(target.Left); err != nil {
+			return err
+		}
+		if err := c.compileOperand(target.Index); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex, int(infix))
+		c.useOperands(2)
+	default:
+		return fmt.Errorf("cannot assign to %s", node.Target.String())
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileHashLiteral(node *syntaxtree.HashLiteral) error {
+	// the pairs of the literal are in a map, sort them to keep the bytecode deterministic
+	keys := make([]syntaxtree.Expr, 0, len(node.Pairs))
+	for key := range node.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	for _, key := range keys {
+		if err := c.compileOperand(key); err != nil {
+			return err
+		}
+		if err := c.compileOperand(node.Pairs[key]); err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpHash, len(keys)*2)
+	c.useOperands(len(keys) * 2)
+	return nil
+}
+
+// declaredNames returns the names that the let and const statements of a function body (or of a loop body)
+// define in its scope, including the ones in the blocks of its if expressions.
+// The functions and the loops inside of the body have scopes of their own.
+func declaredNames(body *syntaxtree.BlockStmt) map[string]bool {
+	names := make(map[string]bool)
+	collectDeclaredNames(body, names)
+	return names
+}
+
+func collectDeclaredNames(node syntaxtree.Node, names map[string]bool) {
+	switch node := node.(type) {
+	case *syntaxtree.BlockStmt:
+		for _, stmt := range node.Statements {
+			collectDeclaredNames(stmt, names)
+		}
+	case *syntaxtree.LetStmt:
+		names[node.Name.Value] = true
+		collectDeclaredNames(node.Value, names)
+	case *syntaxtree.ExpressionStmt:
+		collectDeclaredNames(node.Expression, names)
+	case *syntaxtree.ReturnStmt:
+		collectDeclaredNames(node.ReturnValue, names)
+	case *syntaxtree.WhileStmt:
+		collectDeclaredNames(node.Condition, names)
+	case *syntaxtree.ForStmt:
+		collectDeclaredNames(node.Iterable, names)
+	case *syntaxtree.IfExpr:
+		collectDeclaredNames(node.Condition, names)
+		collectDeclaredNames(node.Consequence, names)
+		if node.Alternative != nil {
+			collectDeclaredNames(node.Alternative, names)
+		}
+	case *syntaxtree.PrefixExpr:
+		collectDeclaredNames(node.Right, names)
+	case *syntaxtree.InfixExpr:
+		collectDeclaredNames(node.Left, names)
+		collectDeclaredNames(node.Right, names)
+	case *syntaxtree.AssignExpr:
+		collectDeclaredNames(node.Target, names)
+		collectDeclaredNames(node.Value, names)
+	case *syntaxtree.CallExpr:
+		collectDeclaredNames(node.Function, names)
+		for _, arg := range node.Arguments {
+			collectDeclaredNames(arg, names)
+		}
+	case *syntaxtree.ArrayLiteral:
+		for _, element := range node.Elements {
+			collectDeclaredNames(element, names)
+		}
+	case *syntaxtree.IndexExpression:
+		collectDeclaredNames(node.Left, names)
+		collectDeclaredNames(node.Index, names)
+	case *syntaxtree.HashLiteral:
+		for key, value := range node.Pairs {
+			collectDeclaredNames(key, names)
+			collectDeclaredNames(value, names)
+		}
+	}
+}
+
+// compileOperand compiles an operand that stays on the stack while the next operands are compiled,
+// until the instruction that uses it marks it as used
+func (c *Compiler) compileOperand(node syntaxtree.Expr) error {
+	if err := c.Compile(node); err != nil {
+		return err
+	}
+
+	c.scopes[c.scopeIndex].operands++
+	return nil
+}
+
+// useOperands marks the given number of operands as used by the instruction that was just emitted
+func (c *Compiler) useOperands(count int) {
+	c.scopes[c.scopeIndex].operands -= count
+}
+
+func (c *Compiler) loadSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, c.nameConstant(symbol.Name))
+	case LocalScope:
+		c.emit(code.OpGetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, symbol.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+func (c *Compiler) storeSymbol(symbol Symbol) {
+	if symbol.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, c.nameConstant(symbol.Name))
+	} else {
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+}
+
+// storeConstant binds a constant, global constants are kept in the environment as such
+func (c *Compiler) storeConstant(symbol Symbol) {
+	if symbol.Scope == GlobalScope {
+		c.emit(code.OpSetConstGlobal, c.nameConstant(symbol.Name))
+	} else {
+		c.storeSymbol(symbol)
+	}
+}
+
+func (c *Compiler) assignSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpAssignGlobal, c.nameConstant(symbol.Name))
+	case LocalScope:
+		c.emit(code.OpAssignLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpAssignFree, symbol.Index)
+	}
+}
+
+// captureSymbol pushes the variable of a symbol that is captured by a closure, see code.OpCaptureLocal
+func (c *Compiler) captureSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, symbol.Index)
+	default:
+		c.loadSymbol(symbol)
+	}
+}
+
+// nameConstant returns the index of the constant that holds the name of a global
+func (c *Compiler) nameConstant(name string) int {
+	if index, ok := c.names[name]; ok {
+		return index
+	}
+
+	index := c.addConstant(&object.String{Value: name})
+	c.names[name] = index
+	return index
+}
+
+// fail emits an instruction that fails with the message, once the program runs into it.
+// The misuse of a constant is an error of eval when the statement runs, not when the function is defined.
+func (c *Compiler) fail(format string, args ...interface{}) {
+	c.emit(code.OpFail, c.addConstant(&object.String{Value: fmt.Sprintf(format, args...)}))
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emit appends the instruction to the current scope and returns its position
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	scope := &c.scopes[c.scopeIndex]
+
+	position := len(scope.instructions)
+	scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+	scope.sourceMap = append(scope.sourceMap, code.SourceMapEntry{Offset: position, Pos: c.pos})
+
+	scope.previousInstruction = scope.lastInstruction
+	scope.lastInstruction = EmittedInstruction{Opcode: op, Position: position}
+
+	return position
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	scope := &c.scopes[c.scopeIndex]
+	last := scope.lastInstruction.Position
+
+	scope.instructions = scope.instructions[:last]
+	scope.sourceMap = scope.sourceMap[:len(scope.sourceMap)-1]
+	scope.lastInstruction = scope.previousInstruction
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	scope := &c.scopes[c.scopeIndex]
+	last := scope.lastInstruction.Position
+
+	c.replaceInstruction(last, code.Make(code.OpReturnValue))
+	scope.lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(position int, instruction []byte) {
+	ins := c.currentInstructions()
+	copy(ins[position:], instruction)
+}
+
+// changeOperand replaces the operand of the instruction at the given position, used to patch jumps
+func (c *Compiler) changeOperand(position int, operand int) {
+	op := code.Opcode(c.currentInstructions()[position])
+	c.replaceInstruction(position, code.Make(op, operand))
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() CompilationScope {
+	scope := c.scopes[c.scopeIndex]
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope
+}
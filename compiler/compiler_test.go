@@ -0,0 +1,368 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/HakanSunay/gohil/code"
+	"github.com/HakanSunay/gohil/lexer"
+	"github.com/HakanSunay/gohil/object"
+	"github.com/HakanSunay/gohil/parser"
+)
+
+func TestCompileInstructions(t *testing.T) {
+	tests := []struct {
+		input        string
+		instructions []code.Instructions
+	}{
+		{
+			"1 + 2",
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"-1 < 2",
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"if (true) { 10 }; 3333;",
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"1 >= 2 || 3 % 2 && true",
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterEqual),
+				code.Make(code.OpJumpTruthyOrPop, 21),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpMod),
+				code.Make(code.OpJumpFalsyOrPop, 21),
+				code.Make(code.OpTrue),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"while (true) { break; continue; }",
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 16),
+				code.Make(code.OpClearLocals, 0, 0),
+				code.Make(code.OpJump, 16),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpEndLoop, 0),
+			},
+		},
+		{
+			"while (true) { [1, if (true) { break }] }",
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 30),
+				code.Make(code.OpClearLocals, 0, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 22),
+				// the element that is already on the stack is popped before leaving the loop
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 30),
+				code.Make(code.OpNull),
+				code.Make(code.OpJump, 23),
+				code.Make(code.OpNull),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpEndLoop, 0),
+			},
+		},
+		{
+			"for (x in []) { x }",
+			[]code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpIterator),
+				code.Make(code.OpNext, 18),
+				// the variable is a local of the iteration
+				code.Make(code.OpClearLocals, 0, 1),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 4),
+				code.Make(code.OpEndLoop, 1),
+			},
+		},
+		{
+			"let one = 1; one;",
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"x += 1",
+			[]code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"[1][0] *= 2",
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSetIndex, int(code.OpMul)),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"[1, 2][0]",
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"len([])",
+			[]code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		bytecode := compile(t, tt.input)
+
+		expected := concatInstructions(tt.instructions)
+		if bytecode.Instructions.String() != expected.String() {
+			t.Errorf("wrong instructions for %q.\nexpected:\n%s\ngot:\n%s", tt.input, expected, bytecode.Instructions)
+		}
+	}
+}
+
+func TestCompileFunctions(t *testing.T) {
+	input := `let counter = fn(n) { let next = n + 1; fn() { counter(next) } };`
+	bytecode := compile(t, input)
+
+	var functions []*object.CompiledFunction
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			functions = append(functions, fn)
+		}
+	}
+	if len(functions) != 2 {
+		t.Fatalf("expected 2 compiled functions, but got %d", len(functions))
+	}
+
+	inner, outer := functions[0], functions[1]
+
+	// both the enclosing function (by its name) and next are captured, next in a cell
+	expectedInner := concatInstructions([]code.Instructions{
+		code.Make(code.OpGetFree, 0),
+		code.Make(code.OpGetFree, 1),
+		code.Make(code.OpCall, 1),
+		code.Make(code.OpReturnValue),
+	})
+	if inner.Instructions.String() != expectedInner.String() {
+		t.Errorf("wrong inner instructions.\nexpected:\n%s\ngot:\n%s", expectedInner, inner.Instructions)
+	}
+
+	expectedOuter := concatInstructions([]code.Instructions{
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpAdd),
+		code.Make(code.OpSetLocal, 1),
+		code.Make(code.OpCurrentClosure),
+		code.Make(code.OpCaptureLocal, 1),
+		code.Make(code.OpClosure, 1, 2),
+		code.Make(code.OpReturnValue),
+	})
+	if outer.Instructions.String() != expectedOuter.String() {
+		t.Errorf("wrong outer instructions.\nexpected:\n%s\ngot:\n%s", expectedOuter, outer.Instructions)
+	}
+
+	if outer.Name != "counter" || outer.NumLocals != 2 || outer.NumParameters != 1 {
+		t.Errorf("unexpected outer function %q with %d locals and %d parameters",
+			outer.Name, outer.NumLocals, outer.NumParameters)
+	}
+
+	for _, fn := range functions {
+		if len(fn.Constants) != len(bytecode.Constants) {
+			t.Errorf("function %q does not share the constants of the program", fn.Name)
+		}
+	}
+}
+
+func TestCompileRecursiveLocalFunction(t *testing.T) {
+	input := `fn() { let loop = fn(n) { loop(n) }; loop(1) }`
+	bytecode := compile(t, input)
+
+	fn := bytecode.Constants[0].(*object.CompiledFunction)
+	expected := concatInstructions([]code.Instructions{
+		code.Make(code.OpCurrentClosure),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpCall, 1),
+		code.Make(code.OpReturnValue),
+	})
+	if fn.Instructions.String() != expected.String() {
+		t.Errorf("wrong instructions.\nexpected:\n%s\ngot:\n%s", expected, fn.Instructions)
+	}
+}
+
+func TestCompileLocalConstants(t *testing.T) {
+	// the misuse of a local constant fails when it runs, after the value is evaluated
+	bytecode := compile(t, "fn() { const n = 1; n = 2 }")
+	fn := bytecode.Constants[3].(*object.CompiledFunction)
+	expected := concatInstructions([]code.Instructions{
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpSetLocal, 0),
+		code.Make(code.OpConstant, 1),
+		code.Make(code.OpFail, 2),
+		code.Make(code.OpReturnValue),
+	})
+	if fn.Instructions.String() != expected.String() {
+		t.Errorf("wrong instructions.\nexpected:\n%s\ngot:\n%s", expected, fn.Instructions)
+	}
+	if message := bytecode.Constants[2].Inspect(); message != "cannot assign to constant n" {
+		t.Errorf("unexpected message %q", message)
+	}
+
+	// global constants are kept in the environment, which refuses to change them
+	bytecode = compile(t, "const n = 1;")
+	expected = concatInstructions([]code.Instructions{
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpSetConstGlobal, 1),
+	})
+	if bytecode.Instructions.String() != expected.String() {
+		t.Errorf("wrong instructions.\nexpected:\n%s\ngot:\n%s", expected, bytecode.Instructions)
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	input := "let a = 1;\na + true;"
+	bytecode := compile(t, input)
+
+	// OpGetGlobal a, OpTrue, OpAdd
+	addOffset := 6 + 3 + 1
+	if pos := bytecode.SourceMap.Lookup(addOffset); pos.String() != "2:1" {
+		t.Errorf("expected OpAdd at 2:1, but got %s", pos)
+	}
+
+	trueOffset := 6 + 3
+	if pos := bytecode.SourceMap.Lookup(trueOffset); pos.String() != "2:5" {
+		t.Errorf("expected OpTrue at 2:5, but got %s", pos)
+	}
+}
+
+func TestSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	outer := NewEnclosedSymbolTable(global)
+	outer.Define("b")
+
+	inner := NewEnclosedSymbolTable(outer)
+	inner.Define("c")
+	inner.DefineFunctionName("self")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope},
+		{Name: "undefined", Scope: GlobalScope},
+		{Name: "b", Scope: FreeScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 0},
+		{Name: "self", Scope: FunctionScope},
+	}
+	for _, symbol := range expected {
+		if resolved := inner.Resolve(symbol.Name); resolved != symbol {
+			t.Errorf("expected %s to resolve to %+v, but got %+v", symbol.Name, symbol, resolved)
+		}
+	}
+
+	// the function name is assigned where it was bound
+	if resolved := inner.ResolveAssignment("self"); resolved != (Symbol{Name: "self", Scope: GlobalScope}) {
+		t.Errorf("expected an assignment to self to resolve to a global, but got %+v", resolved)
+	}
+
+	if len(inner.FreeSymbols) != 1 || inner.FreeSymbols[0] != (Symbol{Name: "b", Scope: LocalScope, Index: 0}) {
+		t.Errorf("unexpected free symbols %+v", inner.FreeSymbols)
+	}
+}
+
+func TestSymbolTableDeclaredNames(t *testing.T) {
+	outer := NewEnclosedSymbolTable(NewSymbolTable())
+	outer.Define("a")
+	outer.Declare(map[string]bool{"later": true})
+	inner := NewEnclosedSymbolTable(outer)
+
+	// the enclosing function itself refers to the name before it defines it, like eval does
+	if resolved := outer.Resolve("later"); resolved != (Symbol{Name: "later", Scope: GlobalScope}) {
+		t.Errorf("expected later to resolve to a global in the enclosing function, but got %+v", resolved)
+	}
+
+	// a function inside of it captures the local that the name is going to be bound to
+	if resolved := inner.Resolve("later"); resolved != (Symbol{Name: "later", Scope: FreeScope, Index: 0}) {
+		t.Errorf("expected later to resolve to a free symbol, but got %+v", resolved)
+	}
+	if defined := outer.Define("later"); defined != (Symbol{Name: "later", Scope: LocalScope, Index: 1}) {
+		t.Errorf("expected the let statement to bind the captured local, but got %+v", defined)
+	}
+	if names := outer.LocalNames(); len(names) != 2 || names[1] != "later" {
+		t.Errorf("unexpected local names %v", names)
+	}
+}
+
+func compile(t *testing.T, input string) *Bytecode {
+	t.Helper()
+
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if errs := p.GetErrors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	c := NewCompiler()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+
+	return c.Bytecode()
+}
+
+func concatInstructions(instructions []code.Instructions) code.Instructions {
+	var out code.Instructions
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
@@ -0,0 +1,197 @@
+package compiler
+
+// SymbolScope describes where the value of a symbol is stored at runtime
+type SymbolScope string
+
+const (
+	// GlobalScope symbols live in the environment of the program and are looked up by name
+	GlobalScope SymbolScope = "GLOBAL"
+	// LocalScope symbols live on the stack of the function that defines them
+	LocalScope SymbolScope = "LOCAL"
+	// FreeScope symbols are locals of an enclosing function captured by a closure
+	FreeScope SymbolScope = "FREE"
+	// FunctionScope is the name of the function being compiled, used for recursion
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+// Symbol is a name together with the location of its value
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// Constant is set for the local constants and the free symbols that capture them
+	Constant bool
+}
+
+// SymbolTable resolves the names of a single function (or the program itself) to symbols,
+// or the names of a block of it that has its own scope, like the body of a loop
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	// names are the names of the local slots, by their index
+	names []string
+	// declared are the names that the let and const statements of the function (or block) define,
+	// the functions inside of it can use them before the statements are compiled
+	declared map[string]bool
+	// block tables define locals in the slots of the function (or program) that contains the block
+	block bool
+
+	// FreeSymbols are the symbols of the enclosing functions that are captured,
+	// in the order of their index in the closure
+	FreeSymbols []Symbol
+}
+
+// NewSymbolTable is the constructor for the SymbolTable of a program
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+// NewEnclosedSymbolTable is the constructor for the SymbolTable of a function inside outer
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// NewBlockSymbolTable is the constructor for the SymbolTable of a block inside outer,
+// the names defined in the block are locals that are not visible outside of it
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
+// Define binds the name in the current scope.
+// The names of the program are globals, the names of functions and blocks are locals.
+// Defining a local name twice reuses its slot, just like let overwrites a binding in the environment.
+func (s *SymbolTable) Define(name string) Symbol {
+	if s.Outer == nil {
+		symbol := Symbol{Name: name, Scope: GlobalScope}
+		s.store[name] = symbol
+		return symbol
+	}
+
+	if symbol, ok := s.store[name]; ok && symbol.Scope == LocalScope {
+		return symbol
+	}
+
+	slots := s.slots()
+	symbol := Symbol{Name: name, Scope: LocalScope, Index: slots.numDefinitions}
+	s.store[name] = symbol
+	slots.numDefinitions++
+	slots.names = append(slots.names, name)
+	return symbol
+}
+
+// slots returns the table of the function (or program) whose frame holds the locals of this table
+func (s *SymbolTable) slots() *SymbolTable {
+	for s.block {
+		s = s.Outer
+	}
+
+	return s
+}
+
+// DefineConstant binds the name like Define, as a constant that cannot be redeclared or assigned.
+// Global constants are not marked, the environment refuses to change them at runtime.
+func (s *SymbolTable) DefineConstant(name string) Symbol {
+	symbol := s.Define(name)
+	if symbol.Scope == LocalScope {
+		symbol.Constant = true
+		s.store[name] = symbol
+	}
+
+	return symbol
+}
+
+// DefineFunctionName binds the name of the function that owns the table
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Declare records the names that the let and const statements of the function (or block) define
+func (s *SymbolTable) Declare(names map[string]bool) {
+	s.declared = names
+}
+
+// Resolve returns the symbol that the name refers to.
+// Names that are not defined by any enclosing function are globals,
+// they are looked up in the environment (or among the builtins) at runtime.
+func (s *SymbolTable) Resolve(name string) Symbol {
+	return s.resolve(name, false)
+}
+
+// resolve returns the symbol of the name, inner reports whether it is used by a function inside of the table.
+// Such a function can be called once the enclosing function has defined the name with a statement
+// that comes after it, just like eval finds the name in the environment of the call.
+// The local is defined right away then, it is not bound until the statement runs.
+func (s *SymbolTable) resolve(name string, inner bool) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+
+	if inner && s.declared[name] {
+		return s.Define(name)
+	}
+
+	if s.Outer == nil {
+		return Symbol{Name: name, Scope: GlobalScope}
+	}
+
+	// the locals of the enclosing scopes of a block are in the same frame
+	symbol := s.Outer.resolve(name, inner || !s.block)
+	if symbol.Scope == GlobalScope || s.block {
+		return symbol
+	}
+
+	return s.defineFree(symbol)
+}
+
+// ResolveAssignment returns the symbol that an assignment to the name updates.
+// It is the symbol that Resolve returns, except for the name of the function being compiled,
+// which is assigned in the enclosing scope that bound it.
+func (s *SymbolTable) ResolveAssignment(name string) Symbol {
+	symbol := s.Resolve(name)
+	if symbol.Scope != FunctionScope {
+		return symbol
+	}
+
+	// the name is bound by the function that contains the blocks
+	fn := s.slots()
+	outer := fn.Outer.resolve(name, true)
+	if outer.Scope == GlobalScope {
+		return outer
+	}
+
+	return fn.defineFree(outer)
+}
+
+// isLocalConstant reports whether the name is a constant defined by this table,
+// which cannot be redeclared in the same scope
+func (s *SymbolTable) isLocalConstant(name string) bool {
+	symbol, ok := s.store[name]
+	return ok && symbol.Scope == LocalScope && symbol.Constant
+}
+
+// NumDefinitions returns the number of local slots the function needs, including the locals of its blocks.
+// The program needs slots for the locals of its blocks only.
+func (s *SymbolTable) NumDefinitions() int {
+	return s.slots().numDefinitions
+}
+
+// LocalNames returns the names of the local slots of the function, see NumDefinitions
+func (s *SymbolTable) LocalNames() []string {
+	return s.slots().names
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1, Constant: original.Constant}
+	s.store[original.Name] = symbol
+	return symbol
+}
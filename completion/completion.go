@@ -0,0 +1,140 @@
+// Package completion suggests how to complete gohil source code that is being typed.
+// It is used by the shell and can be shared by editor integrations.
+package completion
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/HakanSunay/gohil/eval"
+	"github.com/HakanSunay/gohil/lexer"
+	"github.com/HakanSunay/gohil/object"
+	"github.com/HakanSunay/gohil/token"
+)
+
+// Scope provides the bound names that can be completed.
+// *object.Environment and *interpreter.Interpreter implement it.
+type Scope interface {
+	Names() []string
+	Get(name string) (object.Object, bool)
+}
+
+// Result describes the completions of the text before the cursor,
+// every candidate replaces the Prefix, which is the end of that text
+type Result struct {
+	Prefix     string
+	Candidates []string
+}
+
+// hashKeyPattern matches an index expression of a hash with an unfinished string key: h["ke
+var hashKeyPattern = regexp.MustCompile(`([\p{L}_][\p{L}\p{N}_]*)\["([^"]*)$`)
+
+// Complete returns the completions of the text before the cursor.
+// Names are completed from the scope, the default builtins and the keywords.
+// After h[" the string keys of the hash bound to h are completed and the index expression is closed.
+// The candidates are sorted and there are none inside strings and numbers.
+func Complete(scope Scope, before string) Result {
+	if match := hashKeyPattern.FindStringSubmatch(before); match != nil {
+		return Result{Prefix: match[2], Candidates: hashKeys(scope, match[1], match[2])}
+	}
+
+	if insideLiteral(before) {
+		return Result{}
+	}
+
+	prefix := identifierSuffix(before)
+	names := append(append(scope.Names(), eval.BuiltinNames()...), token.Keywords()...)
+	return Result{Prefix: prefix, Candidates: withPrefix(names, prefix)}
+}
+
+// hashKeys returns the string keys of the hash bound to name that start with the prefix,
+// followed by the end of the index expression
+func hashKeys(scope Scope, name string, prefix string) []string {
+	value, ok := scope.Get(name)
+	if !ok {
+		return nil
+	}
+
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return nil
+	}
+
+	var keys []string
+	for _, pair := range hash.Pairs {
+		if key, ok := pair.Key.(*object.String); ok {
+			keys = append(keys, key.Value+`"]`)
+		}
+	}
+
+	return withPrefix(keys, prefix)
+}
+
+// identifierSuffix returns the identifier (or its start) at the end of the text,
+// an identifier cannot start with a digit
+func identifierSuffix(text string) string {
+	start := len(text)
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if !isIdentifierRune(r) {
+			break
+		}
+		start -= size
+	}
+
+	for start < len(text) {
+		r, size := utf8.DecodeRuneInString(text[start:])
+		if unicode.IsLetter(r) || r == '_' {
+			break
+		}
+		start += size
+	}
+
+	return text[start:]
+}
+
+// insideLiteral reports whether the text ends inside a string, a number or a comment
+func insideLiteral(text string) bool {
+	if text == "" {
+		return false
+	}
+
+	var last token.Token
+	l := lexer.NewLexer(text, lexer.WithComments())
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		last = tok
+	}
+
+	switch last.Type {
+	case token.Illegal:
+		return l.Unterminated()
+	case token.Int, token.Float:
+		return last.End.Offset == len(text)
+	case token.Comment:
+		// a line comment runs to the end of the line
+		return strings.HasPrefix(last.Literal, "//") && last.End.Offset == len(text)
+	default:
+		return false
+	}
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// withPrefix returns the sorted, distinct names that start with the prefix
+func withPrefix(names []string, prefix string) []string {
+	sort.Strings(names)
+
+	var candidates []string
+	for i, name := range names {
+		if strings.HasPrefix(name, prefix) && (i == 0 || names[i-1] != name) {
+			candidates = append(candidates, name)
+		}
+	}
+
+	return candidates
+}
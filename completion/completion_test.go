@@ -0,0 +1,47 @@
+package completion
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/HakanSunay/gohil/object"
+)
+
+func TestComplete(t *testing.T) {
+	config := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	for _, key := range []object.Object{&object.String{Value: "host"}, &object.String{Value: "port"}, &object.Integer{Value: 1}} {
+		config.Pairs[key.(object.Hashable).HashKey()] = object.HashPair{Key: key, Value: &object.Integer{Value: 0}}
+	}
+
+	outer := object.NewEnvironment()
+	outer.Set("config", config)
+	outer.Set("lastName", &object.String{Value: "gohil"})
+	env := object.NewEnclosedEnvironment(outer)
+	env.Set("count", &object.Integer{Value: 1})
+
+	tests := []struct {
+		before   string
+		expected Result
+	}{
+		{"la", Result{Prefix: "la", Candidates: []string{"last", "lastName"}}},
+		{"let x = co", Result{Prefix: "co", Candidates: []string{"config", "const", "continue", "count"}}},
+		{"he", Result{Prefix: "he", Candidates: []string{"head"}}},
+		{"f", Result{Prefix: "f", Candidates: []string{"false", "float", "fn", "for", "freeze"}}},
+		{"print(re", Result{Prefix: "re", Candidates: []string{"return"}}},
+		{`config["`, Result{Prefix: "", Candidates: []string{`host"]`, `port"]`}}},
+		{`config["p`, Result{Prefix: "p", Candidates: []string{`port"]`}}},
+		{`count["`, Result{Prefix: ""}},
+		{`"la`, Result{}},
+		{"12", Result{}},
+		{"x // la", Result{}},
+		{"/* la", Result{}},
+		{"/* note */ la", Result{Prefix: "la", Candidates: []string{"last", "lastName"}}},
+		{"xyz", Result{Prefix: "xyz"}},
+	}
+
+	for _, tt := range tests {
+		if result := Complete(env, tt.before); !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%q: expected %+v, but got %+v", tt.before, tt.expected, result)
+		}
+	}
+}
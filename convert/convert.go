@@ -0,0 +1,465 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/HakanSunay/gohil/eval"
+	"github.com/HakanSunay/gohil/object"
+)
+
+// tagName is the struct tag that can be used to rename or skip (with "-") struct fields
+// E.g:
+//
+//	type Server struct {
+//		Host string `gohil:"host"`
+//		Port int    `gohil:"port"`
+//		Key  string `gohil:"-"`
+//	}
+const tagName = "gohil"
+
+var (
+	objectType         = reflect.TypeOf((*object.Object)(nil)).Elem()
+	emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+	bigIntType         = reflect.TypeOf(big.Int{})
+)
+
+// errContainsItself is returned for the arrays and hashes that contain themselves,
+// e.g. after let a = [1]; a[0] = a, converting them would never end
+var errContainsItself = errors.New("cannot convert a value that contains itself")
+
+// ToObject converts a Go value to a gohil object.
+// Supported are nil, booleans, integers, floats, strings, slices, arrays, maps, structs, pointers to those
+// and functions (see Func). Values that already are gohil objects are returned as they are.
+// Structs are converted to hashes with string keys, which are the field names or their gohil tags.
+func ToObject(value interface{}) (object.Object, error) {
+	if value == nil {
+		return eval.Null, nil
+	}
+
+	if obj, ok := value.(object.Object); ok {
+		return obj, nil
+	}
+
+	return valueToObject(reflect.ValueOf(value))
+}
+
+func valueToObject(value reflect.Value) (object.Object, error) {
+	if value.IsValid() && value.Type().Implements(objectType) {
+		if value.IsNil() {
+			return eval.Null, nil
+		}
+		return value.Interface().(object.Object), nil
+	}
+
+	if value.IsValid() && value.Type() == bigIntType {
+		bigValue := value.Interface().(big.Int)
+		return object.IntegerFromBig(new(big.Int).Set(&bigValue)), nil
+	}
+
+	switch value.Kind() {
+	case reflect.Invalid:
+		return eval.Null, nil
+	case reflect.Bool:
+		if value.Bool() {
+			return eval.True, nil
+		}
+		return eval.False, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: int(value.Int())}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return object.IntegerFromBig(new(big.Int).SetUint64(value.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: value.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: value.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return eval.Null, nil
+		}
+		return sliceToObject(value)
+	case reflect.Map:
+		if value.IsNil() {
+			return eval.Null, nil
+		}
+		return mapToObject(value)
+	case reflect.Struct:
+		return structToObject(value)
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return eval.Null, nil
+		}
+		return valueToObject(value.Elem())
+	case reflect.Func:
+		if value.IsNil() {
+			return eval.Null, nil
+		}
+		return Func(value.Interface())
+	default:
+		return nil, fmt.Errorf("unsupported Go type: %s", value.Type())
+	}
+}
+
+func sliceToObject(value reflect.Value) (object.Object, error) {
+	elements := make([]object.Object, value.Len())
+	for i := range elements {
+		element, err := valueToObject(value.Index(i))
+		if err != nil {
+			return nil, fmt.Errorf("index %d: %v", i, err)
+		}
+		elements[i] = element
+	}
+
+	return &object.Array{Elements: elements}, nil
+}
+
+func mapToObject(value reflect.Value) (object.Object, error) {
+	pairs := make(map[object.HashKey]object.HashPair, value.Len())
+
+	iter := value.MapRange()
+	for iter.Next() {
+		key, err := valueToObject(iter.Key())
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %v", iter.Key(), err)
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		val, err := valueToObject(iter.Value())
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %v", iter.Key(), err)
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: val}
+	}
+
+	return &object.Hash{Pairs: pairs}, nil
+}
+
+func structToObject(value reflect.Value) (object.Object, error) {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for _, field := range structFields(value.Type()) {
+		val, err := valueToObject(value.Field(field.index))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", field.name, err)
+		}
+
+		key := &object.String{Value: field.name}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: val}
+	}
+
+	return &object.Hash{Pairs: pairs}, nil
+}
+
+// field describes an exported struct field and the hash key it is mapped to
+type field struct {
+	name  string
+	index int
+}
+
+func structFields(typ reflect.Type) []field {
+	var fields []field
+
+	for i := 0; i < typ.NumField(); i++ {
+		structField := typ.Field(i)
+		// unexported fields are not accessible through reflection
+		if structField.PkgPath != "" {
+			continue
+		}
+
+		name := structField.Name
+		if tag, ok := structField.Tag.Lookup(tagName); ok {
+			tag = strings.Split(tag, ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		fields = append(fields, field{name: name, index: i})
+	}
+
+	return fields
+}
+
+// ToGo converts a gohil object to its natural Go representation:
+// Integer to int (or *big.Int if it does not fit), Float to float64, String to string, Boolean to bool, Null to nil,
+// Array to []interface{} and Hash to map[interface{}]interface{}.
+// Other objects (functions, builtins, errors) are returned as they are.
+// Arrays and hashes that contain themselves cannot be converted.
+func ToGo(obj object.Object) (interface{}, error) {
+	return toGo(obj, make(map[object.Object]bool))
+}
+
+// toGo is ToGo, visiting holds the arrays and hashes that are being converted
+func toGo(obj object.Object, visiting map[object.Object]bool) (interface{}, error) {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.BigInteger:
+		return new(big.Int).Set(obj.Value), nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Array:
+		if err := visit(obj, visiting); err != nil {
+			return nil, err
+		}
+		defer delete(visiting, obj)
+
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := toGo(element, visiting)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *object.Hash:
+		if err := visit(obj, visiting); err != nil {
+			return nil, err
+		}
+		defer delete(visiting, obj)
+
+		pairs := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, err := toGo(pair.Key, visiting)
+			if err != nil {
+				return nil, err
+			}
+			value, err := toGo(pair.Value, visiting)
+			if err != nil {
+				return nil, err
+			}
+			pairs[key] = value
+		}
+		return pairs, nil
+	default:
+		return obj, nil
+	}
+}
+
+// visit marks the array or hash as being converted,
+// visiting it again before its conversion is done means that it contains itself
+func visit(obj object.Object, visiting map[object.Object]bool) error {
+	if visiting[obj] {
+		return errContainsItself
+	}
+
+	visiting[obj] = true
+	return nil
+}
+
+// Decode stores the gohil object in the value pointed to by out,
+// converting it to the type of that value.
+func Decode(obj object.Object, out interface{}) error {
+	target := reflect.ValueOf(out)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("decode target must be a non-nil pointer, got %T", out)
+	}
+
+	value, err := ToGoValue(obj, target.Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	target.Elem().Set(value)
+	return nil
+}
+
+// ToGoValue converts the gohil object to a Go value of the given type
+func ToGoValue(obj object.Object, typ reflect.Type) (reflect.Value, error) {
+	return toGoValue(obj, typ, make(map[object.Object]bool))
+}
+
+// toGoValue is ToGoValue, visiting holds the arrays and hashes that are being converted
+func toGoValue(obj object.Object, typ reflect.Type, visiting map[object.Object]bool) (reflect.Value, error) {
+	if obj == nil {
+		obj = eval.Null
+	}
+
+	if typ == emptyInterfaceType {
+		value, err := toGo(obj, visiting)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if value == nil {
+			return reflect.Zero(typ), nil
+		}
+		return reflect.ValueOf(value), nil
+	}
+
+	// gohil objects can be passed around as they are
+	if reflect.TypeOf(obj).AssignableTo(typ) {
+		return reflect.ValueOf(obj), nil
+	}
+
+	if obj.Type() == object.NullObject {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface, reflect.Func:
+			return reflect.Zero(typ), nil
+		}
+	}
+
+	if typ == bigIntType {
+		switch integer := obj.(type) {
+		case *object.Integer:
+			return reflect.ValueOf(*big.NewInt(int64(integer.Value))), nil
+		case *object.BigInteger:
+			return reflect.ValueOf(*new(big.Int).Set(integer.Value)), nil
+		}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(typ), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if integer, ok := obj.(*object.Integer); ok {
+			value := reflect.New(typ).Elem()
+			if value.OverflowInt(int64(integer.Value)) {
+				return reflect.Value{}, fmt.Errorf("integer overflow: %d does not fit in %s", integer.Value, typ)
+			}
+			value.SetInt(int64(integer.Value))
+			return value, nil
+		}
+		if integer, ok := obj.(*object.BigInteger); ok {
+			return reflect.Value{}, fmt.Errorf("integer overflow: %s does not fit in %s", integer.Inspect(), typ)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if integer, ok := obj.(*object.Integer); ok {
+			value := reflect.New(typ).Elem()
+			if integer.Value < 0 || value.OverflowUint(uint64(integer.Value)) {
+				return reflect.Value{}, fmt.Errorf("integer overflow: %d does not fit in %s", integer.Value, typ)
+			}
+			value.SetUint(uint64(integer.Value))
+			return value, nil
+		}
+		if integer, ok := obj.(*object.BigInteger); ok {
+			value := reflect.New(typ).Elem()
+			if !integer.Value.IsUint64() || value.OverflowUint(integer.Value.Uint64()) {
+				return reflect.Value{}, fmt.Errorf("integer overflow: %s does not fit in %s", integer.Inspect(), typ)
+			}
+			value.SetUint(integer.Value.Uint64())
+			return value, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		// integers are valid floats, but not the other way around
+		switch number := obj.(type) {
+		case *object.Float:
+			return reflect.ValueOf(number.Value).Convert(typ), nil
+		case *object.Integer:
+			return reflect.ValueOf(float64(number.Value)).Convert(typ), nil
+		}
+	case reflect.String:
+		if str, ok := obj.(*object.String); ok {
+			return reflect.ValueOf(str.Value).Convert(typ), nil
+		}
+	case reflect.Slice:
+		if arr, ok := obj.(*object.Array); ok {
+			return arrayToSlice(arr, typ, visiting)
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*object.Hash); ok {
+			return hashToMap(hash, typ, visiting)
+		}
+	case reflect.Struct:
+		if hash, ok := obj.(*object.Hash); ok {
+			return hashToStruct(hash, typ, visiting)
+		}
+	case reflect.Ptr:
+		value, err := toGoValue(obj, typ.Elem(), visiting)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(value)
+		return ptr, nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", obj.Type(), typ)
+}
+
+func arrayToSlice(arr *object.Array, typ reflect.Type, visiting map[object.Object]bool) (reflect.Value, error) {
+	if err := visit(arr, visiting); err != nil {
+		return reflect.Value{}, err
+	}
+	defer delete(visiting, arr)
+
+	slice := reflect.MakeSlice(typ, len(arr.Elements), len(arr.Elements))
+	for i, element := range arr.Elements {
+		value, err := toGoValue(element, typ.Elem(), visiting)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("index %d: %v", i, err)
+		}
+		slice.Index(i).Set(value)
+	}
+
+	return slice, nil
+}
+
+func hashToMap(hash *object.Hash, typ reflect.Type, visiting map[object.Object]bool) (reflect.Value, error) {
+	if err := visit(hash, visiting); err != nil {
+		return reflect.Value{}, err
+	}
+	defer delete(visiting, hash)
+
+	m := reflect.MakeMapWithSize(typ, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		key, err := toGoValue(pair.Key, typ.Key(), visiting)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("key %s: %v", pair.Key.Inspect(), err)
+		}
+
+		value, err := toGoValue(pair.Value, typ.Elem(), visiting)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("key %s: %v", pair.Key.Inspect(), err)
+		}
+
+		m.SetMapIndex(key, value)
+	}
+
+	return m, nil
+}
+
+func hashToStruct(hash *object.Hash, typ reflect.Type, visiting map[object.Object]bool) (reflect.Value, error) {
+	if err := visit(hash, visiting); err != nil {
+		return reflect.Value{}, err
+	}
+	defer delete(visiting, hash)
+
+	value := reflect.New(typ).Elem()
+	for _, field := range structFields(typ) {
+		key := &object.String{Value: field.name}
+
+		// missing keys leave the zero value of the field
+		pair, ok := hash.Pairs[key.HashKey()]
+		if !ok {
+			continue
+		}
+
+		fieldValue, err := toGoValue(pair.Value, typ.Field(field.index).Type, visiting)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("field %s: %v", field.name, err)
+		}
+		value.Field(field.index).Set(fieldValue)
+	}
+
+	return value, nil
+}
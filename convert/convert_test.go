@@ -0,0 +1,263 @@
+package convert
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HakanSunay/gohil/eval"
+	"github.com/HakanSunay/gohil/object"
+)
+
+type server struct {
+	Host    string `gohil:"host"`
+	Port    int    `gohil:"port"`
+	Tags    []string
+	Secret  string `gohil:"-"`
+	private int
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{float32(0.5), "0.5"},
+		{2.0, "2.0"},
+		{"gohil", "gohil"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{&server{Host: "localhost"}, "localhost"},
+		{(*server)(nil), "null"},
+		{[]interface{}{1, "two", nil}, "[1, two, null]"},
+		{&object.Integer{Value: 5}, "5"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.value)
+		if err != nil {
+			t.Errorf("%#v: unexpected error %v", tt.value, err)
+			continue
+		}
+
+		if hash, ok := obj.(*object.Hash); ok && tt.expected == "localhost" {
+			host := hash.Pairs[(&object.String{Value: "host"}).HashKey()]
+			if host.Value.Inspect() != tt.expected {
+				t.Errorf("expected host %q, but got %q", tt.expected, host.Value.Inspect())
+			}
+			continue
+		}
+
+		if obj.Inspect() != tt.expected {
+			t.Errorf("%#v: expected %q, but got %q", tt.value, tt.expected, obj.Inspect())
+		}
+	}
+
+	// singletons are used, so that gohil's truthiness rules apply
+	if obj, _ := ToObject(false); obj != eval.False {
+		t.Errorf("expected the False singleton")
+	}
+	if obj, _ := ToObject(nil); obj != eval.Null {
+		t.Errorf("expected the Null singleton")
+	}
+
+	if _, err := ToObject(make(chan int)); err == nil {
+		t.Errorf("expected an error for unsupported types")
+	}
+	// integers that do not fit in an Integer are promoted
+	if obj, _ := ToObject(uint64(1 << 63)); obj.Inspect() != "9223372036854775808" {
+		t.Errorf("expected a big integer, but got %v", obj)
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	value, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	obj, err := ToObject(value)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := obj.(*object.BigInteger); !ok || obj.Inspect() != value.String() {
+		t.Fatalf("expected BigInteger %s, but got %T %s", value, obj, obj.Inspect())
+	}
+
+	var decoded *big.Int
+	if err := Decode(obj, &decoded); err != nil || decoded.Cmp(value) != 0 {
+		t.Errorf("expected %s, but got %s (%v)", value, decoded, err)
+	}
+
+	var small int64
+	if err := Decode(obj, &small); err == nil {
+		t.Errorf("expected an overflow error")
+	}
+
+	var unsigned uint64
+	if err := Decode(&object.BigInteger{Value: new(big.Int).SetUint64(1 << 63)}, &unsigned); err != nil || unsigned != 1<<63 {
+		t.Errorf("expected %d, but got %d (%v)", uint64(1<<63), unsigned, err)
+	}
+
+	if converted, err := ToGo(obj); err != nil || converted.(*big.Int).Cmp(value) != 0 {
+		t.Errorf("expected ToGo to return %s", value)
+	}
+}
+
+func TestStructRoundTrip(t *testing.T) {
+	original := server{Host: "localhost", Port: 8080, Tags: []string{"a", "b"}, Secret: "hidden", private: 1}
+
+	obj, err := ToObject(original)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hash := obj.(*object.Hash)
+	if len(hash.Pairs) != 3 {
+		t.Errorf("expected 3 pairs, but got %d: %s", len(hash.Pairs), hash.Inspect())
+	}
+
+	var decoded server
+	if err := Decode(obj, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := server{Host: "localhost", Port: 8080, Tags: []string{"a", "b"}}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, decoded)
+	}
+}
+
+func TestToGo(t *testing.T) {
+	obj := &object.Array{Elements: []object.Object{
+		&object.Integer{Value: 1},
+		&object.String{Value: "two"},
+		eval.True,
+		eval.Null,
+	}}
+
+	expected := []interface{}{1, "two", true, nil}
+	if value, err := ToGo(obj); err != nil || !reflect.DeepEqual(value, expected) {
+		t.Errorf("expected %#v, but got %#v (%v)", expected, value, err)
+	}
+}
+
+func TestValuesThatContainThemselves(t *testing.T) {
+	// let shared = [1]; let arr = [shared, shared]; let self = [1]; self[0] = self
+	shared := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}}}
+	arr := &object.Array{Elements: []object.Object{shared, shared}}
+	self := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}}}
+	self.Elements[0] = self
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+	key := &object.String{Value: "self"}
+	hash.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: &object.Array{Elements: []object.Object{hash}}}
+
+	// the same value can be converted more than once, as long as it does not contain itself
+	var nested [][]int
+	if err := Decode(arr, &nested); err != nil || !reflect.DeepEqual(nested, [][]int{{1}, {1}}) {
+		t.Errorf("expected [[1] [1]], but got %v (%v)", nested, err)
+	}
+	if value, err := ToGo(arr); err != nil || !reflect.DeepEqual(value, []interface{}{[]interface{}{1}, []interface{}{1}}) {
+		t.Errorf("expected [[1] [1]], but got %v (%v)", value, err)
+	}
+
+	tests := []struct {
+		obj    object.Object
+		target interface{}
+	}{
+		{self, new([]interface{})},
+		{self, new(interface{})},
+		{hash, new(interface{})},
+		{hash, new(map[string][]interface{})},
+		{hash, new(struct {
+			Self []map[string]interface{} `gohil:"self"`
+		})},
+	}
+	for _, tt := range tests {
+		if err := Decode(tt.obj, tt.target); err == nil || !strings.Contains(err.Error(), errContainsItself.Error()) {
+			t.Errorf("Decode of %s into %T: expected %q, but got %v", tt.obj.Inspect(), tt.target, errContainsItself, err)
+		}
+		if _, err := ToGo(tt.obj); err != errContainsItself {
+			t.Errorf("ToGo of %s: expected %q, but got %v", tt.obj.Inspect(), errContainsItself, err)
+		}
+	}
+
+	// a Go function that takes a slice gets an error instead of overflowing the stack
+	count, err := Func(func(values []interface{}) int { return len(values) })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "ERROR: argument 1: index 0: " + errContainsItself.Error()
+	if result := count.Fn(self).Inspect(); result != expected {
+		t.Errorf("expected %q, but got %q", expected, result)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var i8 int8
+	if err := Decode(&object.Integer{Value: 300}, &i8); err == nil {
+		t.Errorf("expected an overflow error")
+	}
+
+	var s string
+	if err := Decode(&object.Integer{Value: 1}, &s); err == nil {
+		t.Errorf("expected a conversion error")
+	}
+
+	if err := Decode(&object.Integer{Value: 1}, s); err == nil {
+		t.Errorf("expected an error for a non pointer target")
+	}
+}
+
+func TestFunc(t *testing.T) {
+	repeat, err := Func(func(s string, n int) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		return strings.Repeat(s, n), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sum, err := Func(func(numbers ...int) int {
+		total := 0
+		for _, n := range numbers {
+			total += n
+		}
+		return total
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		builtin  *object.Builtin
+		args     []object.Object
+		expected string
+	}{
+		{repeat, []object.Object{&object.String{Value: "ab"}, &object.Integer{Value: 2}}, "abab"},
+		{repeat, []object.Object{&object.String{Value: "ab"}, &object.Integer{Value: -1}}, "ERROR: negative count"},
+		{repeat, []object.Object{&object.String{Value: "ab"}}, "ERROR: wrong number of arguments. got=1, want=2"},
+		{repeat, []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}, "ERROR: argument 1: cannot convert Integer to string"},
+		{sum, nil, "0"},
+		{sum, []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}, "3"},
+	}
+
+	for _, tt := range tests {
+		if result := tt.builtin.Fn(tt.args...).Inspect(); result != tt.expected {
+			t.Errorf("expected %q, but got %q", tt.expected, result)
+		}
+	}
+
+	if _, err := Func(42); err == nil {
+		t.Errorf("expected an error for a non function")
+	}
+	if _, err := Func(func() (int, int) { return 1, 2 }); err == nil {
+		t.Errorf("expected an error for unsupported results")
+	}
+}
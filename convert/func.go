@@ -0,0 +1,120 @@
+package convert
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/HakanSunay/gohil/eval"
+	"github.com/HakanSunay/gohil/object"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Func wraps an arbitrary Go function as a gohil builtin.
+// The arguments of every call are checked and converted to the parameter types of the function,
+// variadic functions are supported as well.
+// The function can return nothing, a single value, an error or a value and an error.
+// Returned values are converted with ToObject and returned errors become gohil errors.
+// E.g:
+//
+//	builtin, err := convert.Func(func(s string, n int) (string, error) { ... })
+func Func(fn interface{}) (*object.Builtin, error) {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func || value.IsNil() {
+		return nil, fmt.Errorf("expected a function, got %T", fn)
+	}
+
+	typ := value.Type()
+	if err := verifyResults(typ); err != nil {
+		return nil, err
+	}
+
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			in, err := convertArguments(typ, args)
+			if err != nil {
+				return err
+			}
+
+			return convertResults(value.Call(in))
+		},
+	}, nil
+}
+
+// verifyResults makes sure that the results of the function can be mapped to a single gohil object
+func verifyResults(typ reflect.Type) error {
+	switch typ.NumOut() {
+	case 0, 1:
+		return nil
+	case 2:
+		if typ.Out(1) == errorType {
+			return nil
+		}
+		return fmt.Errorf("the second result of %s must be an error", typ)
+	default:
+		return fmt.Errorf("%s returns too many results, at most a value and an error are supported", typ)
+	}
+}
+
+func convertArguments(typ reflect.Type, args []object.Object) ([]reflect.Value, *object.Error) {
+	want := typ.NumIn()
+	if typ.IsVariadic() {
+		if len(args) < want-1 {
+			return nil, newError("wrong number of arguments. got=%d, want at least %d", len(args), want-1)
+		}
+	} else if len(args) != want {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		paramType := parameterType(typ, i)
+
+		value, err := ToGoValue(arg, paramType)
+		if err != nil {
+			return nil, newError("argument %d: %v", i+1, err)
+		}
+		in[i] = value
+	}
+
+	return in, nil
+}
+
+// parameterType returns the type of the i-th argument of a call,
+// arguments past the last parameter of a variadic function have the type of its elements
+func parameterType(typ reflect.Type, i int) reflect.Type {
+	if typ.IsVariadic() && i >= typ.NumIn()-1 {
+		return typ.In(typ.NumIn() - 1).Elem()
+	}
+
+	return typ.In(i)
+}
+
+func convertResults(results []reflect.Value) object.Object {
+	if len(results) == 0 {
+		return eval.Null
+	}
+
+	last := results[len(results)-1]
+	if last.Type() == errorType {
+		if !last.IsNil() {
+			return newError("%v", last.Interface())
+		}
+		results = results[:len(results)-1]
+	}
+
+	if len(results) == 0 {
+		return eval.Null
+	}
+
+	obj, err := valueToObject(results[0])
+	if err != nil {
+		return newError("invalid result: %v", err)
+	}
+
+	return obj
+}
+
+func newError(format string, args ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, args...)}
+}
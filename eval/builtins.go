@@ -2,6 +2,14 @@ package eval
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/HakanSunay/gohil/object"
 )
@@ -15,9 +23,9 @@ var builtins = map[string]*object.Builtin{
 			}
 
 			switch arg := args[0].(type) {
-			// if the fist argument is a string, just find its len using the GoLang len keyword
+			// the length of a string is the number of its characters, see bytelen for the number of bytes
 			case *object.String:
-				return &object.Integer{Value: len(arg.Value)}
+				return &object.Integer{Value: utf8.RuneCountInString(arg.Value)}
 			// we can always add a new case here for custom behaviour for certain object.Object :)
 			// make it work for int as well, but what is the LEN of an int? (no one knows, yet :) )
 			case *object.Array:
@@ -27,6 +35,21 @@ var builtins = map[string]*object.Builtin{
 			}
 		},
 	},
+	// bytelen is the length of the UTF-8 encoding of a string
+	"bytelen": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument of `bytelen` must be of type String, got %s", args[0].Type())
+			}
+
+			return &object.Integer{Value: len(str.Value)}
+		},
+	},
 	// Calling this head to remind myself of the painful logical programming days
 	"head": {
 		Fn: func(args ...object.Object) object.Object {
@@ -113,20 +136,98 @@ var builtins = map[string]*object.Builtin{
 			return &object.Array{Elements: newElements}
 		},
 	},
-	"print": {
+	// int converts floats (truncating them towards zero) and strings to integers
+	"int": {
 		Fn: func(args ...object.Object) object.Object {
-			for _, a := range args {
-				// This is given the fact that STDOUT is used,
-				// if in any scenario we want to do it any other way around
-				// we can also create a object.String with a string builder
-				// and after the loop assigning the builder value to a object.String
-				// but that could mean that the object can be used somewhere else
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
 
-				// but print is all about printing to stdout and not producing values!!!
-				fmt.Println(a.Inspect())
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInteger:
+				return arg
+			case *object.Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return newError("cannot convert %s to Integer", arg.Inspect())
+				}
+				value, _ := big.NewFloat(arg.Value).Int(nil)
+				return object.IntegerFromBig(value)
+			case *object.String:
+				value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10)
+				if !ok {
+					return newError("cannot convert %q to Integer", arg.Value)
+				}
+				return object.IntegerFromBig(value)
+			default:
+				return newError("argument of `int` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	// float converts integers and strings to floats
+	"float": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
-			return Null
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInteger:
+				return &object.Float{Value: toFloat(arg)}
+			case *object.Float:
+				return arg
+			case *object.String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError("cannot convert %q to Float", arg.Value)
+				}
+				return &object.Float{Value: value}
+			default:
+				return newError("argument of `float` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	// freeze makes arrays and hashes deeply immutable, see object.Freeze
+	"freeze": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return object.Freeze(args[0])
 		},
 	},
+	"print":  NewPrintBuiltin(os.Stdout),
+	"eprint": NewPrintBuiltin(os.Stderr),
+}
+
+// LookupBuiltin returns the default builtin function with the given name
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// BuiltinNames returns the sorted names of the default builtin functions
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// NewPrintBuiltin creates a print builtin that writes the inspected arguments to the given writer,
+// one per line. The default print builtin writes to STDOUT, hosts can shadow it in their environment.
+func NewPrintBuiltin(writer io.Writer) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			for _, a := range args {
+				// print is all about printing and not producing values!!!
+				fmt.Fprintln(writer, a.Inspect())
+			}
+
+			return Null
+		},
+	}
 }
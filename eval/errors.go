@@ -0,0 +1,33 @@
+package eval
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/HakanSunay/gohil/logger"
+	"github.com/HakanSunay/gohil/object"
+)
+
+// DivisionByZeroMessage is the message of the error returned by divisions (and other operations) by zero
+const DivisionByZeroMessage = "division by zero"
+
+// FrozenMessage is the format of the error returned by the modifications of frozen arrays and hashes,
+// builtins that modify them in place must return it too
+const FrozenMessage = "cannot modify a frozen %s"
+
+// internalErrorPrefix starts the message of errors that are caused by a bug in gohil or in a builtin
+const internalErrorPrefix = "internal error: "
+
+// RecoverInternalError is the recover boundary of an execution, it must be deferred directly.
+// A panic inside of the execution (e.g. in a builtin) is converted to an internal error
+// that becomes the result, instead of crashing the whole process.
+// The details of the panic are logged with the logger of the context.
+func RecoverInternalError(ctx context.Context, result *object.Object) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	logger.GetFromContext(ctx).Errorf("Recovered from a panic during evaluation: %v\n%s", r, debug.Stack())
+	*result = newError(internalErrorPrefix+"%v", r)
+}
@@ -1,10 +1,16 @@
 package eval
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"sort"
 
 	"github.com/HakanSunay/gohil/object"
 	"github.com/HakanSunay/gohil/syntaxtree"
+	"github.com/HakanSunay/gohil/token"
 )
 
 var (
@@ -13,74 +19,169 @@ var (
 	False = &object.Boolean{Value: false}
 )
 
+// anonymousFunctionName is used in the call stack for functions that are not called by name
+const anonymousFunctionName = "<anonymous>"
+
+// evaluator holds the state of a single evaluation, which is the stack
+// of the function calls that are currently being evaluated and the resources used so far
+type evaluator struct {
+	frames []object.Frame
+	// depth is the depth of the call stack of the caller, when the evaluation is a call made by another backend
+	depth  int
+	budget *Budget
+	// tracer is nil, unless the evaluation is traced
+	tracer *tracer
+}
+
+func newEvaluator(ctx context.Context, limits Limits) *evaluator {
+	return &evaluator{budget: NewBudget(ctx, limits), tracer: newTracer(ctx)}
+}
+
+func newEvaluatorWithBudget(budget *Budget, depth int) *evaluator {
+	return &evaluator{depth: depth, budget: budget, tracer: newTracer(budget.ctx)}
+}
+
+// Eval evaluates the given node in the given environment, with the default limits
 func Eval(node syntaxtree.Node, environment *object.Environment) object.Object {
+	return EvalContext(context.Background(), node, environment, Limits{})
+}
+
+// EvalContext evaluates the given node in the given environment.
+// The evaluation ends with an error when the context is done or when the limits are exceeded.
+// It is traced if the context enables it, see WithTrace.
+func EvalContext(ctx context.Context, node syntaxtree.Node, environment *object.Environment, limits Limits) (result object.Object) {
+	defer RecoverInternalError(ctx, &result)
+
+	return newEvaluator(ctx, limits).eval(node, environment)
+}
+
+// eval evaluates the node and makes sure that errors produced by it know where they come from.
+// Errors are created without a position, therefore the first (innermost) node
+// that evaluates to an error without position is the one that failed.
+func (e *evaluator) eval(node syntaxtree.Node, environment *object.Environment) object.Object {
+	if e.tracer != nil {
+		e.tracer.enterNode(node)
+	}
+
+	var result object.Object
+	if err := e.budget.Step(); err != nil {
+		result = err
+	} else {
+		result = e.evalNode(node, environment)
+	}
+
+	if errObj, ok := result.(*object.Error); ok && !errObj.Pos.IsValid() {
+		errObj.Pos = node.Pos()
+		errObj.Stack = e.stack()
+	}
+
+	if e.tracer != nil {
+		e.tracer.exitNode(result)
+	}
+
+	return result
+}
+
+// stack returns a snapshot of the current call stack
+func (e *evaluator) stack() []object.Frame {
+	stack := make([]object.Frame, len(e.frames))
+	copy(stack, e.frames)
+	return stack
+}
+
+func (e *evaluator) evalNode(node syntaxtree.Node, environment *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements:
 	case *syntaxtree.Program:
-		return evalProgram(node.Statements, environment) // start traversing the program tree
+		return e.evalProgram(node.Statements, environment) // start traversing the program tree
 	case *syntaxtree.ExpressionStmt:
-		return Eval(node.Expression, environment)
+		return e.eval(node.Expression, environment)
 	case *syntaxtree.BlockStmt:
-		return evalBlockStatement(node, environment)
+		return e.evalBlockStatement(node, environment)
 	case *syntaxtree.ReturnStmt:
-		val := Eval(node.ReturnValue, environment)
-		if isError(val) {
+		val := e.eval(node.ReturnValue, environment)
+		if isInterrupted(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *syntaxtree.LetStmt:
-		val := Eval(node.Value, environment)
-		if isError(val) {
+		val := e.eval(node.Value, environment)
+		if isInterrupted(val) {
 			return val
 		}
-		environment.Set(node.Name.Value, val)
+		bind := environment.Set
+		if node.Constant() {
+			bind = environment.SetConst
+		}
+		if err := bind(node.Name.Value, val); err != nil {
+			return err
+		}
+	case *syntaxtree.WhileStmt:
+		return e.evalWhileStatement(node, environment)
+	case *syntaxtree.ForStmt:
+		return e.evalForStatement(node, environment)
+	case *syntaxtree.BranchStmt:
+		if node.Token.Type == token.Break {
+			return breakBranch
+		}
+		return continueBranch
 
 	// Expressions:
 	case *syntaxtree.Identifier:
 		return evalIdentifier(node, environment)
 	case *syntaxtree.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
+	case *syntaxtree.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *syntaxtree.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *syntaxtree.BooleanLiteral:
 		return parseToBooleanInstance(node.Value)
 	case *syntaxtree.ArrayLiteral:
-		elements := evalExpressions(node.Elements, environment)
-		if len(elements) == 1 && isError(elements[0]) {
+		elements := e.evalExpressions(node.Elements, environment)
+		if len(elements) == 1 && isInterrupted(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 	case *syntaxtree.HashLiteral:
-		return evalHashLiteral(node, environment)
+		return e.evalHashLiteral(node, environment)
 	// hil supports 2 prefix operators: ! (excl. Mark / Bang) and - (minus)
 	case *syntaxtree.PrefixExpr:
-		right := Eval(node.Right, environment)
-		if isError(right) {
+		right := e.eval(node.Right, environment)
+		if isInterrupted(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *syntaxtree.InfixExpr:
-		left := Eval(node.Left, environment)
-		if isError(left) {
+		left := e.eval(node.Left, environment)
+		if isInterrupted(left) {
 			return left
 		}
-		right := Eval(node.Right, environment)
-		if isError(right) {
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, left, environment)
+		}
+		right := e.eval(node.Right, environment)
+		if isInterrupted(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
+	case *syntaxtree.AssignExpr:
+		return e.evalAssignExpression(node, environment)
 	case *syntaxtree.IfExpr:
-		return evalIfExpression(node, environment)
+		return e.evalIfExpression(node, environment)
 	case *syntaxtree.CallExpr:
-		function := Eval(node.Function, environment)
-		if isError(function) {
+		function := e.eval(node.Function, environment)
+		if isInterrupted(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, environment)
-		if len(args) == 1 && isError(args[0]) {
+		args := e.evalExpressions(node.Arguments, environment)
+		if len(args) == 1 && isInterrupted(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return e.applyFunction(object.Frame{Function: calleeName(node), Pos: node.Pos()}, function, args)
 	case *syntaxtree.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
@@ -90,13 +191,13 @@ func Eval(node syntaxtree.Node, environment *object.Environment) object.Object {
 			Env:        environment,
 		}
 	case *syntaxtree.IndexExpression:
-		left := Eval(node.Left, environment)
-		if isError(left) {
+		left := e.eval(node.Left, environment)
+		if isInterrupted(left) {
 			return left
 		}
 
-		index := Eval(node.Index, environment)
-		if isError(index) {
+		index := e.eval(node.Index, environment)
+		if isInterrupted(index) {
 			return index
 		}
 
@@ -106,13 +207,13 @@ func Eval(node syntaxtree.Node, environment *object.Environment) object.Object {
 	return nil
 }
 
-func evalExpressions(exprs []syntaxtree.Expr, env *object.Environment) []object.Object {
+func (e *evaluator) evalExpressions(exprs []syntaxtree.Expr, env *object.Environment) []object.Object {
 	var result []object.Object
 
 	// also evaluation from LEFT to RIGHT
-	for _, e := range exprs {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+	for _, expr := range exprs {
+		evaluated := e.eval(expr, env)
+		if isInterrupted(evaluated) {
 			// this ensures the error check for len 1
 			return []object.Object{evaluated}
 		}
@@ -123,12 +224,12 @@ func evalExpressions(exprs []syntaxtree.Expr, env *object.Environment) []object.
 	return result
 }
 
-func evalProgram(statements []syntaxtree.Stmt, environment *object.Environment) object.Object {
+func (e *evaluator) evalProgram(statements []syntaxtree.Stmt, environment *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range statements {
 		// last evaluated statement will end up as the result
-		result = Eval(stmt, environment)
+		result = e.eval(stmt, environment)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -141,20 +242,133 @@ func evalProgram(statements []syntaxtree.Stmt, environment *object.Environment)
 	return result
 }
 
-func evalBlockStatement(block *syntaxtree.BlockStmt, environment *object.Environment) object.Object {
+func (e *evaluator) evalBlockStatement(block *syntaxtree.BlockStmt, environment *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
-		result = Eval(statement, environment)
+		result = e.eval(statement, environment)
 		if result != nil {
 			rt := result.Type()
-			if rt == object.ReturnValueObject || rt == object.ErrorObject {
+			if rt == object.ReturnValueObject || rt == object.ErrorObject || rt == object.BranchObject {
 				return result
 			}
 		}
 	}
+
+	// a block that is empty or ends with a statement that has no value (e.g. let) evaluates to null
+	if result == nil {
+		return Null
+	}
 	return result
 }
 
+// breakBranch and continueBranch are the results of break and continue statements
+var (
+	breakBranch    = &object.Branch{Break: true}
+	continueBranch = &object.Branch{Break: false}
+)
+
+// evalWhileStatement evaluates the body as long as the condition is truthy,
+// loops are statements and their value is null
+func (e *evaluator) evalWhileStatement(node *syntaxtree.WhileStmt, environment *object.Environment) object.Object {
+	for {
+		condition := e.eval(node.Condition, environment)
+		if isInterrupted(condition) {
+			return condition
+		}
+		if !IsTruthy(condition) {
+			return Null
+		}
+
+		if result, done := e.evalLoopBody(node.Body, object.NewEnclosedEnvironment(environment)); done {
+			return result
+		}
+	}
+}
+
+// evalForStatement binds every value of the iterable to the variable, in the environment of the iteration,
+// and evaluates the body for it. The values are taken before the first iteration.
+func (e *evaluator) evalForStatement(node *syntaxtree.ForStmt, environment *object.Environment) object.Object {
+	iterable := e.eval(node.Iterable, environment)
+	if isInterrupted(iterable) {
+		return iterable
+	}
+
+	values, err := iterate(iterable)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		iteration := object.NewEnclosedEnvironment(environment)
+		iteration.Set(node.Variable.Value, value)
+
+		if result, done := e.evalLoopBody(node.Body, iteration); done {
+			return result
+		}
+	}
+
+	return Null
+}
+
+// evalLoopBody evaluates an iteration of a loop, done reports whether the loop ends after it.
+// Every iteration has its own environment, so the bindings of the body are not shared by the iterations.
+// A return statement or an error end the loop and the result is passed on to the enclosing function,
+// break ends it without a result.
+func (e *evaluator) evalLoopBody(body *syntaxtree.BlockStmt, environment *object.Environment) (result object.Object, done bool) {
+	switch result := e.eval(body, environment).(type) {
+	case *object.Branch:
+		return Null, result.Break
+	case *object.ReturnValue, *object.Error:
+		return result, true
+	default:
+		return Null, false
+	}
+}
+
+func iterate(iterable object.Object) ([]object.Object, *object.Error) {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		values := make([]object.Object, len(iterable.Elements))
+		copy(values, iterable.Elements)
+		return values, nil
+	case *object.Hash:
+		return sortedKeys(iterable), nil
+	case *object.String:
+		var values []object.Object
+		for _, ch := range iterable.Value {
+			values = append(values, &object.String{Value: string(ch)})
+		}
+		return values, nil
+	default:
+		return nil, newError("cannot iterate over %s", iterable.Type())
+	}
+}
+
+// sortedKeys returns the keys of the hash in a stable order:
+// numbers come first by their value, the rest are sorted by their type and then by their value
+func sortedKeys(hash *object.Hash) []object.Object {
+	keys := make([]object.Object, 0, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		keys = append(keys, pair.Key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		left, right := keys[i], keys[j]
+		switch {
+		case isNumber(left) && isNumber(right):
+			return toFloat(left) < toFloat(right)
+		case isNumber(left) != isNumber(right):
+			return isNumber(left)
+		case left.Type() != right.Type():
+			return left.Type() < right.Type()
+		default:
+			return left.Inspect() < right.Inspect()
+		}
+	})
+
+	return keys
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
@@ -180,17 +394,28 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalNegativeValueExpression(right object.Object) object.Object {
-	if right.Type() != object.IntegerObject {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == minInt {
+			return object.IntegerFromBig(new(big.Int).Neg(toBigInt(right)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return object.IntegerFromBig(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	return &object.Integer{Value: -(right.(*object.Integer).Value)}
 }
 
 func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.IntegerObject && right.Type() == object.IntegerObject:
 		return evalIntegerInfixExpression(operator, left, right)
+	// mixed integer and float operands are converted to floats
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.BooleanObject && right.Type() == object.BooleanObject:
 		return evalBooleanInfixExpression(operator, left, right)
 	case left.Type() == object.StringObject && right.Type() == object.StringObject:
@@ -202,21 +427,142 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	}
 }
 
+// minInt is the smallest value of Integer, negating it or dividing it by -1 overflows
+const minInt = -1 << (bits.UintSize - 1)
+
 func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
-	// type assertions should have already been done using the Type method
-	// still might need to guard for panics
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+	// big integers use the slow path, so do operations that overflow
+	leftInt, leftSmall := left.(*object.Integer)
+	rightInt, rightSmall := right.(*object.Integer)
+	if !leftSmall || !rightSmall {
+		return evalBigIntegerInfixExpression(operator, left, right)
+	}
+
+	leftVal := leftInt.Value
+	rightVal := rightInt.Value
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		sum := leftVal + rightVal
+		if (rightVal > 0 && sum < leftVal) || (rightVal < 0 && sum > leftVal) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: sum}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		difference := leftVal - rightVal
+		if (rightVal > 0 && difference > leftVal) || (rightVal < 0 && difference < leftVal) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: difference}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		product := leftVal * rightVal
+		if leftVal != 0 && (product/leftVal != rightVal || (leftVal == -1 && rightVal == minInt)) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: product}
 	case "/":
+		if rightVal == 0 {
+			return newError(DivisionByZeroMessage)
+		}
+		if leftVal == minInt && rightVal == -1 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError(DivisionByZeroMessage)
+		}
+		// the remainder has the sign of the dividend, minInt % -1 is 0
+		return &object.Integer{Value: leftVal % rightVal}
+	case "==":
+		return parseToBooleanInstance(leftVal == rightVal)
+	case "!=":
+		return parseToBooleanInstance(leftVal != rightVal)
+	case ">":
+		return parseToBooleanInstance(leftVal > rightVal)
+	case "<":
+		return parseToBooleanInstance(leftVal < rightVal)
+	case ">=":
+		return parseToBooleanInstance(leftVal >= rightVal)
+	case "<=":
+		return parseToBooleanInstance(leftVal <= rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalBigIntegerInfixExpression applies the operator with arbitrary precision,
+// results that fit in an Integer are demoted back to it
+func evalBigIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+
+	switch operator {
+	case "+":
+		return object.IntegerFromBig(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return object.IntegerFromBig(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return object.IntegerFromBig(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError(DivisionByZeroMessage)
+		}
+		// Quo truncates towards zero, just like the division of Go integers
+		return object.IntegerFromBig(new(big.Int).Quo(leftVal, rightVal))
+	case "%":
+		if rightVal.Sign() == 0 {
+			return newError(DivisionByZeroMessage)
+		}
+		// Rem goes with Quo, the remainder has the sign of the dividend
+		return object.IntegerFromBig(new(big.Int).Rem(leftVal, rightVal))
+	case "==":
+		return parseToBooleanInstance(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return parseToBooleanInstance(leftVal.Cmp(rightVal) != 0)
+	case ">":
+		return parseToBooleanInstance(leftVal.Cmp(rightVal) > 0)
+	case "<":
+		return parseToBooleanInstance(leftVal.Cmp(rightVal) < 0)
+	case ">=":
+		return parseToBooleanInstance(leftVal.Cmp(rightVal) >= 0)
+	case "<=":
+		return parseToBooleanInstance(leftVal.Cmp(rightVal) <= 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// toBigInt converts an Integer or a BigInteger to a big.Int, the caller must not modify the result
+func toBigInt(obj object.Object) *big.Int {
+	if integer, ok := obj.(*object.Integer); ok {
+		return big.NewInt(int64(integer.Value))
+	}
+
+	return obj.(*object.BigInteger).Value
+}
+
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError(DivisionByZeroMessage)
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError(DivisionByZeroMessage)
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
 	case "==":
 		return parseToBooleanInstance(leftVal == rightVal)
 	case "!=":
@@ -225,11 +571,33 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 		return parseToBooleanInstance(leftVal > rightVal)
 	case "<":
 		return parseToBooleanInstance(leftVal < rightVal)
+	case ">=":
+		return parseToBooleanInstance(leftVal >= rightVal)
+	case "<=":
+		return parseToBooleanInstance(leftVal <= rightVal)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
+// isNumber reports whether the object is an Integer or a Float
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.IntegerObject || obj.Type() == object.FloatObject
+}
+
+// toFloat converts a number to a Go float, the caller must make sure that it is a number
+func toFloat(obj object.Object) float64 {
+	switch number := obj.(type) {
+	case *object.Integer:
+		return float64(number.Value)
+	case *object.BigInteger:
+		value, _ := new(big.Float).SetInt(number.Value).Float64()
+		return value
+	default:
+		return obj.(*object.Float).Value
+	}
+}
+
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	switch operator {
 	case "+":
@@ -269,6 +637,17 @@ func evalBooleanInfixExpression(operator string, left object.Object, right objec
 	}
 }
 
+// evalLogicalExpression evaluates && and ||, the right operand is only evaluated when the left one
+// does not decide the result. The result is the deciding operand, which is not necessarily a boolean:
+// 0 || "default" is 0, because 0 is truthy, and null || "default" is "default".
+func (e *evaluator) evalLogicalExpression(node *syntaxtree.InfixExpr, left object.Object, environment *object.Environment) object.Object {
+	if IsTruthy(left) == (node.Operator == "||") {
+		return left
+	}
+
+	return e.eval(node.Right, environment)
+}
+
 func parseToBooleanInstance(p bool) *object.Boolean {
 	if p {
 		return True
@@ -276,18 +655,18 @@ func parseToBooleanInstance(p bool) *object.Boolean {
 	return False
 }
 
-func evalIfExpression(node *syntaxtree.IfExpr, environment *object.Environment) object.Object {
-	condition := Eval(node.Condition, environment)
-	if isError(condition) {
+func (e *evaluator) evalIfExpression(node *syntaxtree.IfExpr, environment *object.Environment) object.Object {
+	condition := e.eval(node.Condition, environment)
+	if isInterrupted(condition) {
 		return condition
 	}
 
 	// This is referred to as being "truthy"
 	// this means that we can evaluate expr like if 5 { ... }
-	if truthy := condition != Null && condition != False; truthy {
-		return Eval(node.Consequence, environment)
+	if IsTruthy(condition) {
+		return e.eval(node.Consequence, environment)
 	} else if node.Alternative != nil {
-		return Eval(node.Alternative, environment)
+		return e.eval(node.Alternative, environment)
 	} else {
 		return Null
 	}
@@ -312,20 +691,70 @@ func newError(format string, args ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, args...)}
 }
 
-// isError is used when checking for errors whenever we call Eval inside of Eval,
-// in order to stop errors from being passed around and then bubbling up far away from their origin
-func isError(obj object.Object) bool {
-	if obj != nil {
-		return obj.Type() == object.ErrorObject
+// isInterrupted is used whenever we call Eval inside of Eval, it reports whether the evaluation
+// of the enclosing node has to stop and pass the object on. Errors do that, so that they are not
+// passed around and then bubbling up far away from their origin, and so do the values of return
+// statements and the branches of break and continue, which leave the nodes around them.
+func isInterrupted(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Error, *object.ReturnValue, *object.Branch:
+		return true
+	default:
+		return false
+	}
+}
+
+// Apply calls the given function or builtin with the given arguments, with the default limits.
+// It is used by hosts to call gohil functions, name is only used for the call stack of errors.
+func Apply(name string, fn object.Object, args ...object.Object) object.Object {
+	return ApplyContext(context.Background(), Limits{}, name, fn, args...)
+}
+
+// ApplyContext is Apply with a context and limits, see EvalContext
+func ApplyContext(ctx context.Context, limits Limits, name string, fn object.Object, args ...object.Object) (result object.Object) {
+	defer RecoverInternalError(ctx, &result)
+
+	return newEvaluator(ctx, limits).applyFunction(object.Frame{Function: name}, fn, args)
+}
+
+// ApplyBudget is Apply for a caller that already keeps track of the resources of the execution, e.g. the virtual machine.
+// The steps of the call are counted against the budget and depth is the depth of the call stack of the caller.
+func ApplyBudget(budget *Budget, depth int, name string, fn object.Object, args ...object.Object) (result object.Object) {
+	defer RecoverInternalError(budget.ctx, &result)
+
+	return newEvaluatorWithBudget(budget, depth).applyFunction(object.Frame{Function: name}, fn, args)
+}
+
+// applyFunction calls the function, frame describes the call in the call stack
+func (e *evaluator) applyFunction(frame object.Frame, fn object.Object, args []object.Object) object.Object {
+	if e.tracer == nil {
+		return e.callFunction(frame, fn, args)
 	}
-	return false
+
+	e.tracer.enterCall(frame.Function, args)
+	result := e.callFunction(frame, fn, args)
+	e.tracer.exitCall(frame.Function, result)
+
+	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (e *evaluator) callFunction(frame object.Frame, fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+		}
+
+		if err := e.budget.EnterCall(e.depth + len(e.frames)); err != nil {
+			return err
+		}
+
+		// the function body is evaluated in a new frame of the call stack
+		e.frames = append(e.frames, frame)
+		defer func() { e.frames = e.frames[:len(e.frames)-1] }()
+
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		evaluated := e.eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
 		return fn.Fn(args...)
@@ -334,6 +763,15 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
+// calleeName returns the name that is used for the called function in the call stack
+func calleeName(call *syntaxtree.CallExpr) string {
+	if identifier, ok := call.Function.(*syntaxtree.Identifier); ok {
+		return identifier.Value
+	}
+
+	return anonymousFunctionName
+}
+
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
@@ -356,6 +794,9 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 	// arr[INTEGER]
 	case left.Type() == object.ArrayObject && index.Type() == object.IntegerObject:
 		return evalArrayIndexExpression(left, index)
+	// str[INTEGER]
+	case left.Type() == object.StringObject && index.Type() == object.IntegerObject:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HashObject:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -363,6 +804,103 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 	}
 }
 
+// evalAssignExpression assigns to an identifier or to an element of an array or a hash.
+// The target is evaluated before the value, a compound assignment reads the current value after both.
+func (e *evaluator) evalAssignExpression(node *syntaxtree.AssignExpr, environment *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *syntaxtree.Identifier:
+		var current object.Object
+		if operator := node.InfixOperator(); operator != "" {
+			current = evalIdentifier(target, environment)
+			if isInterrupted(current) {
+				return current
+			}
+		}
+
+		value := e.evalAssignedValue(node, current, environment)
+		if isInterrupted(value) {
+			return value
+		}
+
+		if err := environment.Assign(target.Value, value); err != nil {
+			return err
+		}
+		return value
+	case *syntaxtree.IndexExpression:
+		left := e.eval(target.Left, environment)
+		if isInterrupted(left) {
+			return left
+		}
+		index := e.eval(target.Index, environment)
+		if isInterrupted(index) {
+			return index
+		}
+
+		var current object.Object
+		if operator := node.InfixOperator(); operator != "" {
+			current = evalIndexExpression(left, index)
+			if isInterrupted(current) {
+				return current
+			}
+		}
+
+		value := e.evalAssignedValue(node, current, environment)
+		if isInterrupted(value) {
+			return value
+		}
+
+		return evalSetIndex(left, index, value)
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+// evalAssignedValue evaluates the value of the assignment,
+// a compound assignment applies its operator to the current value of the target and to it
+func (e *evaluator) evalAssignedValue(node *syntaxtree.AssignExpr, current object.Object, environment *object.Environment) object.Object {
+	value := e.eval(node.Value, environment)
+	if isInterrupted(value) || current == nil {
+		return value
+	}
+
+	return evalInfixExpression(node.InfixOperator(), current, value)
+}
+
+// evalSetIndex replaces the element of an array or the value of a hash key,
+// unlike reading an element, writing one out of the range of an array is an error
+func evalSetIndex(left object.Object, index object.Object, value object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		if left.Frozen {
+			return newError(FrozenMessage, left.Type())
+		}
+		if index.Type() != object.IntegerObject {
+			return newError("array index must be an Integer, got %s", index.Type())
+		}
+
+		integer, ok := index.(*object.Integer)
+		if !ok || integer.Value < 0 || integer.Value >= len(left.Elements) {
+			return newError("index %s out of range for an Array of length %d", index.Inspect(), len(left.Elements))
+		}
+
+		left.Elements[integer.Value] = value
+		return value
+	case *object.Hash:
+		if left.Frozen {
+			return newError(FrozenMessage, left.Type())
+		}
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: value}
+		return value
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
 func evalHashIndexExpression(hash object.Object, index object.Object) object.Object {
 	// already verified
 	hashObject := hash.(*object.Hash)
@@ -388,7 +926,13 @@ func evalArrayIndexExpression(arr object.Object, index object.Object) object.Obj
 
 	// TODO: can do some python magic like returning last element if -1 is the index
 	// or some special case if the index value is 999 999 always return the fist element
-	i := index.(*object.Integer).Value
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		// big integers are out of range for sure
+		return Null
+	}
+
+	i := integer.Value
 	max := len(arrayObject.Elements) - 1
 	if i < 0 || i > max {
 		return Null
@@ -397,12 +941,31 @@ func evalArrayIndexExpression(arr object.Object, index object.Object) object.Obj
 	return arrayObject.Elements[i]
 }
 
-func evalHashLiteral(node *syntaxtree.HashLiteral, environment *object.Environment) object.Object {
+// evalStringIndexExpression returns the character at the index as a string,
+// strings are indexed by characters and not by bytes
+func evalStringIndexExpression(str object.Object, index object.Object) object.Object {
+	integer, ok := index.(*object.Integer)
+	if !ok || integer.Value < 0 {
+		return Null
+	}
+
+	i := 0
+	for _, ch := range str.(*object.String).Value {
+		if i == integer.Value {
+			return &object.String{Value: string(ch)}
+		}
+		i++
+	}
+
+	return Null
+}
+
+func (e *evaluator) evalHashLiteral(node *syntaxtree.HashLiteral, environment *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 	for keyNode, valueNode := range node.Pairs {
 		// evaluate the key
-		key := Eval(keyNode, environment)
-		if isError(key) {
+		key := e.eval(keyNode, environment)
+		if isInterrupted(key) {
 			return key
 		}
 
@@ -413,8 +976,8 @@ func evalHashLiteral(node *syntaxtree.HashLiteral, environment *object.Environme
 		}
 
 		// evaluate the value
-		value := Eval(valueNode, environment)
-		if isError(value) {
+		value := e.eval(valueNode, environment)
+		if isInterrupted(value) {
 			return value
 		}
 
@@ -1,11 +1,23 @@
-package eval
+package eval_test
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/sirupsen/logrus"
+
+	"github.com/HakanSunay/gohil/compiler"
+	"github.com/HakanSunay/gohil/eval"
 	"github.com/HakanSunay/gohil/lexer"
+	"github.com/HakanSunay/gohil/logger"
 	"github.com/HakanSunay/gohil/object"
 	"github.com/HakanSunay/gohil/parser"
+	"github.com/HakanSunay/gohil/vm"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -30,13 +42,157 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"10 % 4 * 2", 4},
+		{"2 + 10 % 4", 4},
 	}
 	for _, tt := range tests {
-		evaluatedObj := evaluate(tt.input)
+		evaluatedObj := evaluate(t, tt.input)
 		verifyIntegerObj(t, evaluatedObj, tt.output)
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"3.14", "3.14"},
+		{"-2.5", "-2.5"},
+		{"1e3", "1000.0"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"1 + 0.5", "1.5"},
+		{"3 / 2.0", "1.5"},
+		{"10 / 4", "2"},
+		{"2.5 * 2", "5.0"},
+		{"1.5 - 2", "-0.5"},
+		{"1.5 > 1", "true"},
+		{"1 < 0.5", "false"},
+		{"2 == 2.0", "true"},
+		{"2.0 != 2", "false"},
+		{"7.5 % 2", "1.5"},
+		{"-7.5 % 2", "-1.5"},
+		{"99999999999999999999 % 7", "1"},
+		{"2.5 >= 2.5", "true"},
+		{"2 <= 1.5", "false"},
+		{"{1: 10}[1.0]", "10"},
+		{"{2.5: 10}[2.5]", "10"},
+		{"int(3.99)", "3"},
+		{"int(-3.99)", "-3"},
+		{`int("42")`, "42"},
+		{"float(2)", "2.0"},
+		{`float("1.25")`, "1.25"},
+		{"-2.5 + true", "ERROR: type mismatch: Float + Boolean"},
+		{`int("x")`, `ERROR: cannot convert "x" to Integer`},
+		{"int(1e20)", "100000000000000000000"},
+		{"int(-1e308 * 10)", "ERROR: cannot convert -Inf to Integer"},
+	}
+	for _, tt := range tests {
+		evaluated := evaluate(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, but got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEvalBigIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"9223372036854775807 * 2", "18446744073709551614"},
+		{"-9223372036854775807 - 1", "-9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"123456789012345678901234567890 - 123456789012345678901234567890 + 5", "5"},
+		{"-123456789012345678901234567890 / 10", "-12345678901234567890123456789"},
+		{"99999999999999999999 > 9223372036854775807", "true"},
+		{"1 < 99999999999999999999", "true"},
+		{"99999999999999999999 == 99999999999999999999", "true"},
+		{"99999999999999999999 != 1", "true"},
+		{"99999999999999999999 * 1.0", "1e+20"},
+		{"{99999999999999999999: 1}[99999999999999999998 + 1]", "1"},
+		{"[1, 2][99999999999999999999]", "null"},
+		{"float(99999999999999999999)", "1e+20"},
+		{`int("99999999999999999999")`, "99999999999999999999"},
+	}
+	for _, tt := range tests {
+		evaluated := evaluate(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, but got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// results that fit again are demoted to plain integers
+	evaluated := evaluate(t, "99999999999999999999 - 99999999999999999998")
+	verifyIntegerObj(t, evaluated, 1)
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	tests := []string{
+		"1 / 0",
+		"1.5 / 0",
+		"1 / 0.0",
+		"1 % 0",
+		"1.5 % 0",
+		"99999999999999999999 % 0",
+		"99999999999999999999 / 0",
+		"let f = fn(x) { 10 / x }; f(0)",
+	}
+	for _, input := range tests {
+		evaluated := evaluate(t, input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error, but got %s", input, evaluated.Inspect())
+			continue
+		}
+		if errObj.Message != eval.DivisionByZeroMessage {
+			t.Errorf("%s: expected %q, but got %q", input, eval.DivisionByZeroMessage, errObj.Message)
+		}
+	}
+}
+
+func TestPanicsBecomeInternalErrors(t *testing.T) {
+	var logs bytes.Buffer
+	previous := logger.StdLog
+	logger.StdLog = logrus.New()
+	logger.StdLog.SetOutput(&logs)
+	defer func() { logger.StdLog = previous }()
+
+	boom := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		panic("boom")
+	}}
+	program := parser.NewParser(lexer.NewLexer("let f = fn() { boom() }; f()")).ParseProgram()
+
+	env := object.NewEnvironment()
+	env.Set("boom", boom)
+	result := eval.Eval(program, env)
+
+	c := compiler.NewCompiler()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+	env = object.NewEnvironment()
+	env.Set("boom", boom)
+	vmResult := vm.New(c.Bytecode(), env).Run()
+
+	for _, obj := range []object.Object{result, vmResult, eval.Apply("boom", boom)} {
+		errObj, ok := obj.(*object.Error)
+		if !ok || errObj.Message != "internal error: boom" {
+			t.Errorf("expected an internal error, but got %v", obj)
+		}
+	}
+
+	if !strings.Contains(logs.String(), "Recovered from a panic during evaluation: boom") {
+		t.Errorf("expected the panic to be logged, but got %q", logs.String())
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input  string
@@ -54,6 +210,12 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"9 == 6", false},
 		{"9 != 6", true},
 
+		{"6 <= 6", true},
+		{"6 <= 5", false},
+		{"6 >= 6", true},
+		{"5 >= 6", false},
+		{"1 < 2 == 2 >= 1", true},
+
 		{"true == true", true},
 		{"false == false", true},
 		{"true == false", false},
@@ -65,7 +227,7 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(6 > 9) == false", true},
 	}
 	for _, tt := range tests {
-		evaluatedObj := evaluate(tt.input)
+		evaluatedObj := evaluate(t, tt.input)
 		verifyBooleanObj(t, evaluatedObj, tt.output)
 	}
 }
@@ -83,7 +245,7 @@ func TestEvalNegateExpression(t *testing.T) {
 		{"!!6", true},
 	}
 	for _, tt := range tests {
-		evaluatedObj := evaluate(tt.input)
+		evaluatedObj := evaluate(t, tt.input)
 		verifyBooleanObj(t, evaluatedObj, tt.output)
 	}
 }
@@ -102,7 +264,7 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (6 < 9) { 10 } else { 20 }", 10},
 	}
 	for _, tt := range tests {
-		evaluated := evaluate(tt.input)
+		evaluated := evaluate(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			verifyIntegerObj(t, evaluated, integer)
@@ -112,6 +274,238 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true && false", "false"},
+		{"true && true", "true"},
+		{"false || true", "true"},
+		{"false || false", "false"},
+		// the deciding operand is the result
+		{`1 && "one"`, "one"},
+		{`0 || "default"`, "0"},
+		{`if (false) { 1 } || "default"`, "default"},
+		{`if (false) { 1 } && "never"`, "null"},
+		{"false || 2 && 3", "3"},
+		{"let a = 5; if (a > 0 && a < 10) { a } else { -1 }", "5"},
+		{"let a = 15; a > 0 && a < 10 || a == 15", "true"},
+		// the right operand is not evaluated when the left one decides the result
+		{"let fail = fn() { 1 / 0 }; false && fail()", "false"},
+		{"let fail = fn() { 1 / 0 }; true || fail()", "true"},
+		{"let fail = fn() { 1 / 0 }; true && fail()", "ERROR: division by zero"},
+		{"let f = fn(x) { x > 0 && x }; [f(3), f(-3)]", "[3, false]"},
+	}
+	for _, tt := range tests {
+		evaluated := evaluate(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, but got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let i = 0; let sum = 0; while (i < 5) { i = i + 1; sum = sum + i; }; sum", "15"},
+		{"let i = 0; while (false) { let i = 1; }; i", "0"},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum = sum + x; }; sum", "6"},
+		{`let keys = ""; for (k in {"b": 1, "c": 2, "a": 3}) { keys = keys + k; }; keys`, "abc"},
+		{`let keys = []; for (k in {"a": 1, 10: 2, 2: 3, true: 4}) { keys = append(keys, k); }; keys`, `[2, 10, true, a]`},
+		{`let out = ""; for (ch in "héllo") { out = ch + out; }; out`, "olléh"},
+		{"let x = 10; for (x in [x, x + 1]) { }; x", "10"},
+		{"for (x in []) { 1 / 0 }; 5", "5"},
+		// break and continue
+		{"let i = 0; while (true) { i = i + 1; if (i == 3) { break; } }; i", "3"},
+		{"let sum = 0; for (x in [1, 2, 3, 4, 5]) { if (x % 2 == 0) { continue; } sum = sum + x; }; sum", "9"},
+		{"let n = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { if (y == 2) { break } n = n + 1; } }; n", "2"},
+		// return leaves the loop and the function
+		{"let find = fn(xs, v) { for (x in xs) { if (x == v) { return true; } }; false }; [find([1, 2], 2), find([1, 2], 3)]", "[true, false]"},
+		{"let f = fn() { let i = 0; while (true) { i = i + 1; if (i > 2) { return i; } } }; f()", "3"},
+		{"let f = fn() { for (x in [1]) { } }; f()", "null"},
+		{"for (x in [1, 2]) { if (x == 2) { return x * 10; } }; 0", "20"},
+		// break, continue and return leave the expressions they are part of
+		{"let out = []; for (x in [1, 2, 3]) { let y = if (x == 2) { continue; } else { x }; out = append(out, y); }; out", "[1, 3]"},
+		{"let out = []; for (x in [1, 2, 3]) { out = append(out, if (x == 2) { break; } else { x }); }; out", "[1]"},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += if (x == 2) { continue } else { x } }; sum", "4"},
+		{"let n = 0; while (n < 5) { n += 1; [n, if (n < 3) { continue } else { break }] }; n", "3"},
+		{"fn() { while (if (true) { return 7 }) { } 3 }()", "7"},
+		{"fn() { for (x in if (true) { return 7 }) { } 3 }()", "7"},
+		{"let n = 0; for (x in [1, 2]) { while (if (x == 1) { continue } else { false }) { } n += x }; n", "2"},
+		{"let f = fn() { let x = if (true) { return 1 }; 2 }; f()", "1"},
+		// every iteration has its own scope
+		{"let x = 1; while (x < 3) { let y = x; x += 1 }; y", "ERROR: identifier not found: y"},
+		{"let x = 1; for (i in [1, 2]) { let x = i }; x", "1"},
+		{"let fns = []; for (i in [1, 2, 3]) { fns = append(fns, fn() { i }) }; [fns[0](), fns[2]()]", "[1, 3]"},
+		{"let fns = []; let i = 0; while (i < 2) { let j = i; fns = append(fns, fn() { j }); i += 1 }; [fns[0](), fns[1]()]", "[0, 1]"},
+		{"let f = fn() { let fns = []; for (i in [1, 2]) { let k = i * 10; fns = append(fns, fn() { k += 1 }) }; fns[0](); [fns[0](), fns[1]()] }; f()", "[12, 21]"},
+		{"let f = fn() { let s = 0; for (i in [1, 2]) { let t = s; s = t + i }; s }; f()", "3"},
+		// the value of a loop is null
+		{"while (false) { }", "null"},
+		{"for (x in [1, 2]) { x }", "null"},
+		{"let f = fn(xs) { for (x in xs) { x } }; [f([1]), f([])]", "[null, null]"},
+		{"let f = fn() { let i = 0; while (i < 2) { i += 1 } }; append([], f())", "[null]"},
+		{"let x = if (true) { while (false) { } }; [x, !x]", "[null, true]"},
+		{"let f = fn() { let x = 1 }; [f(), if (true) { }]", "[null, null]"},
+		// errors
+		{"for (x in 5) { }", "ERROR: cannot iterate over Integer"},
+		{"for (x in [1, 0]) { 10 / x }", "ERROR: division by zero"},
+		{"while (y) { }", "ERROR: identifier not found: y"},
+	}
+	for _, tt := range tests {
+		evaluated := evaluate(t, tt.input)
+		if evaluated == nil {
+			evaluated = eval.Null
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, but got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; x = 2; x", "2"},
+		{"let x = 1; x = x + 1", "2"},
+		{"let x = 1; let y = 2; x = y = 3; [x, y]", "[3, 3]"},
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i; }; sum", "15"},
+		{"let x = 10; x -= 3; x *= 4; x /= 7; x", "4"},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		// the nearest binding is updated, in the enclosing function or in the globals
+		{"let x = 1; let f = fn() { x = 5 }; f(); x", "5"},
+		{"let x = 1; let f = fn() { let x = 2; x = 5 }; f(); x", "1"},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", "3"},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let a = counter(); let b = counter(); a(); a(); b()", "1"},
+		{"let f = fn() { let n = 1; let get = fn() { n }; n = 2; get() }; f()", "2"},
+		{"let f = fn() { let n = 0; let add = fn() { fn() { n += 10 } }; add()(); add()(); n }; f()", "20"},
+		{"let f = fn() { f = 5 }; f(); f", "5"},
+		// elements of arrays and hashes
+		{"let a = [1, 2, 3]; a[1] = 5; a", "[1, 5, 3]"},
+		{"let a = [1, 2, 3]; a[2] *= 10; a", "[1, 2, 30]"},
+		{`let h = {"a": 1}; h["a"] += 1; h["b"] = 3; [h["a"], h["b"]]`, "[2, 3]"},
+		{"let a = [[1], [2]]; a[1][0] = 7; a", "[[1], [7]]"},
+		{"let a = [1]; let b = a; b[0] = 2; a", "[2]"},
+		{"let a = [0, 0]; for (i in [0, 1]) { a[i] = i + 1 }; a", "[1, 2]"},
+		{"let a = [1]; a[0] = 2", "2"},
+		// values that contain themselves
+		{"let a = [1, 2]; a[1] = a; a", "[1, [...]]"},
+		{`let h = {}; h["self"] = [h]; h`, "{self: [{...}]}"},
+		// errors
+		{"y = 1", "ERROR: identifier not found: y"},
+		{"y += 1", "ERROR: identifier not found: y"},
+		{"let f = fn() { z = 1 }; f()", "ERROR: identifier not found: z"},
+		{"let a = [1]; a[1] = 2", "ERROR: index 1 out of range for an Array of length 1"},
+		{"let a = [1]; a[-1] = 2", "ERROR: index -1 out of range for an Array of length 1"},
+		{`let a = [1]; a["0"] = 2`, "ERROR: array index must be an Integer, got String"},
+		{"let h = {}; h[fn() {}] = 1", "ERROR: unusable as hash key: Function"},
+		{`let s = "ab"; s[0] = "c"`, "ERROR: index assignment not supported: String"},
+		{"let h = {}; h[1] += 1", "ERROR: type mismatch: Null + Integer"},
+		{"let x = 1; x /= 0", "ERROR: division by zero"},
+	}
+	for _, tt := range tests {
+		evaluated := evaluate(t, tt.input)
+		if evaluated == nil {
+			evaluated = eval.Null
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, but got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const x = 1; x + 1", "2"},
+		{"const x = 1; let f = fn() { let x = 2; x }; [f(), x]", "[2, 1]"},
+		{"const x = 1; let f = fn(x) { x += 1 }; [f(5), x]", "[6, 1]"},
+		{"let f = fn() { const n = 3; fn() { n * 2 } }; f()()", "6"},
+		// the global constants cannot be changed
+		{"const x = 1; x = 2", "ERROR: cannot assign to constant x"},
+		{"const x = 1; x += 2", "ERROR: cannot assign to constant x"},
+		{"const x = 1; let f = fn() { x = 2 }; f()", "ERROR: cannot assign to constant x"},
+		{"const x = 1; let x = 2", "ERROR: cannot redeclare constant x"},
+		{"const x = 1; const x = 2", "ERROR: cannot redeclare constant x"},
+		{"const x = 1; for (x in [1, 2]) { }; x", "1"},
+		{"let x = 1; const x = 2; x = 3", "ERROR: cannot assign to constant x"},
+		// a constant of a loop body is bound again in every iteration
+		{"let s = 0; for (i in [1, 2]) { const z = i; s += z; }; s", "3"},
+		{"let f = fn() { let s = 0; for (i in [1, 2]) { const z = i; s += z; } s }; f()", "3"},
+		// the binding is constant, its value can still be modified
+		{"const a = [1]; a[0] = 2; a", "[2]"},
+	}
+	for _, tt := range tests {
+		evaluated := evaluate(t, tt.input)
+		if evaluated == nil {
+			evaluated = eval.Null
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, but got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+// TestLocalConstants checks the constants of functions and loop bodies, which are locals of the virtual machine
+func TestLocalConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn() { const n = 1; n = 2 }; f()", "ERROR: cannot assign to constant n"},
+		{"let f = fn() { const n = 1; let n = 2 }; f()", "ERROR: cannot redeclare constant n"},
+		{"let f = fn() { const n = 1; fn() { n += 1 } }; f()()", "ERROR: cannot assign to constant n"},
+		{"let i = 0; while (i < 2) { const z = i; i += 1; z = 5 }", "ERROR: cannot assign to constant z"},
+		{"let f = fn() { const n = 1; let n = 1 / 0 }; f()", "ERROR: division by zero"},
+		{"let f = fn() { const n = 1; n += 1 / 0 }; f()", "ERROR: division by zero"},
+		// the misuse is an error once it runs
+		{"let f = fn() { const n = 1; n = 2 }; 5", "5"},
+		{"let f = fn(x) { const n = 1; if (x) { n = 2 }; n }; f(false)", "1"},
+	}
+	for _, tt := range tests {
+		evaluated := evaluate(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, but got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"freeze([1, 2])", "[1, 2]"},
+		{"freeze(5)", "5"},
+		{`let a = freeze([1, [2]]); append(a, 3)`, "[1, [2], 3]"},
+		{`let a = freeze([1]); let b = append(a, 2); b[0] = 5; [a, b]`, "[[1], [5, 2]]"},
+		{"let a = [1]; freeze(a); a[0] = 2", "ERROR: cannot modify a frozen Array"},
+		{"let a = freeze([1]); a[0] += 1", "ERROR: cannot modify a frozen Array"},
+		{`let h = freeze({"a": 1}); h["b"] = 2`, "ERROR: cannot modify a frozen Hash"},
+		// nested arrays and hashes are frozen too
+		{`let h = freeze({"a": [1]}); h["a"][0] = 2`, "ERROR: cannot modify a frozen Array"},
+		{`let a = freeze([{"k": 1}]); a[0]["k"] = 2`, "ERROR: cannot modify a frozen Hash"},
+		{"let a = [1]; a[0] = a; freeze(a); a[0][0] = 2", "ERROR: cannot modify a frozen Array"},
+		{"freeze()", "ERROR: wrong number of arguments. got=0, want=1"},
+	}
+	for _, tt := range tests {
+		evaluated := evaluate(t, tt.input)
+		if evaluated == nil {
+			evaluated = eval.Null
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, but got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -128,9 +522,19 @@ func TestReturnStatements(t *testing.T) {
 			}
 		  	return 12;
           }`, 6},
+		// a return inside of an expression leaves the function, the rest of the expression is not evaluated
+		{"fn() { [if (true) { return 1 }, 2]; 3 }()", 1},
+		{`fn() { {"a": if (true) { return 1 }}; 3 }()`, 1},
+		{"fn() { len(if (true) { return 1 }) }()", 1},
+		{"fn() { 2 * -if (true) { return 1 } }()", 1},
+		{"fn() { [1, 2][if (true) { return 1 }] }()", 1},
+		{"fn() { let x = 0; x += if (true) { return 1 }; x }()", 1},
+		{"fn() { if (if (true) { return 1 }) { 2 } else { 3 } }()", 1},
+		{"let f = fn(x) { x * 10 }; fn() { f(if (true) { return 1 }) }()", 1},
+		{"[if (true) { return 1 }, 2]; 3", 1},
 	}
 	for _, tt := range tests {
-		evaluated := evaluate(tt.input)
+		evaluated := evaluate(t, tt.input)
 		verifyIntegerObj(t, evaluated, tt.expected)
 	}
 }
@@ -181,9 +585,38 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: Function",
 		},
+		{
+			"let outer = fn() { let h = fn() { later }; let r = h(); let later = 5; r }; outer()",
+			"identifier not found: later",
+		},
+		// a local is not bound when its let statement is skipped
+		{
+			"let f = fn() { if (false) { let c = 1 }; c }; f()",
+			"identifier not found: c",
+		},
+		{
+			"let f = fn() { if (false) { let c = 1 }; c = 3 }; f()",
+			"identifier not found: c",
+		},
+		{
+			"let f = fn() { if (false) { let c = 1 }; c += 3 }; f()",
+			"identifier not found: c",
+		},
+		{
+			"let f = fn() { if (false) { let c = 1 }; fn() { c } }; f()()",
+			"identifier not found: c",
+		},
+		{
+			"let f = fn() { if (false) { let c = 1 }; fn() { c = 2 } }; f()()",
+			"identifier not found: c",
+		},
+		{
+			"for (x in [1]) { if (x > 1) { let c = 1 }; c }",
+			"identifier not found: c",
+		},
 	}
 	for _, tt := range tests {
-		evaluated := evaluate(tt.input)
+		evaluated := evaluate(t, tt.input)
 		errObj, ok := evaluated.(*object.Error)
 		if !ok {
 			t.Errorf("no error object returned. got=%T(%+v)",
@@ -208,13 +641,13 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
 	}
 	for _, tt := range tests {
-		verifyIntegerObj(t, evaluate(tt.input), tt.expected)
+		verifyIntegerObj(t, evaluate(t, tt.input), tt.expected)
 	}
 }
 
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
-	evaluated := evaluate(input)
+	evaluated := evaluate(t, input)
 
 	fn, ok := evaluated.(*object.Function)
 	if !ok {
@@ -246,15 +679,26 @@ func TestFunctionApplication(t *testing.T) {
 		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
 		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
 		{"fn(x) { x; }(5)", 5},
+		// a function can use the locals that the enclosing function defines after it
+		{"let outer = fn() { let h = fn() { later }; let later = 5; h() }; outer()", 5},
+		{"let outer = fn() { let h = fn() { later = 7 }; let later = 5; h(); later }; outer()", 7},
+		{"let outer = fn() { let h = fn() { fn() { later } }; if (true) { let later = 5 }; h()() }; outer()", 5},
+		{`let f = fn(n) {
+			let even = fn(n) { if (n == 0) { 1 } else { odd(n - 1) } };
+			let odd = fn(n) { if (n == 0) { 0 } else { even(n - 1) } };
+			even(n) * 10 + odd(n)
+		}; f(4)`, 10},
+		{"let f = fn() { let s = 0; for (i in [1, 2]) { let g = fn() { k }; let k = i * 2; s += g() }; s }; f()", 6},
+		{"let later = 1; let outer = fn() { let x = later; let later = 5; x }; outer()", 1},
 	}
 	for _, tt := range tests {
-		verifyIntegerObj(t, evaluate(tt.input), tt.expected)
+		verifyIntegerObj(t, evaluate(t, tt.input), tt.expected)
 	}
 }
 
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
-	evaluated := evaluate(input)
+	evaluated := evaluate(t, input)
 	str, ok := evaluated.(*object.String)
 	if !ok {
 		t.Fatalf("expected object type String, but got %T", evaluated)
@@ -266,7 +710,7 @@ func TestStringLiteral(t *testing.T) {
 
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
-	evaluated := evaluate(input)
+	evaluated := evaluate(t, input)
 	str, ok := evaluated.(*object.String)
 	if !ok {
 		t.Fatalf("expected object type String, but got %T", evaluated)
@@ -286,9 +730,12 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("hello world")`, 11},
 		{`len(1)`, "argument of `len` not supported, got Integer"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len("şehir 🌉")`, 7},
+		{`bytelen("şehir 🌉")`, 11},
+		{`bytelen([])`, "argument of `bytelen` must be of type String, got Array"},
 	}
 	for _, tt := range tests {
-		evaluated := evaluate(tt.input)
+		evaluated := evaluate(t, tt.input)
 		switch expected := tt.expected.(type) {
 		case int:
 			verifyIntegerObj(t, evaluated, expected)
@@ -307,7 +754,7 @@ func TestBuiltinFunctions(t *testing.T) {
 
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
-	evaluated := evaluate(input)
+	evaluated := evaluate(t, input)
 	result, ok := evaluated.(*object.Array)
 	if !ok {
 		t.Fatalf("expected object type Array, but got %T", evaluated)
@@ -367,7 +814,7 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		evaluated := evaluate(tt.input)
+		evaluated := evaluate(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			verifyIntegerObj(t, evaluated, integer)
@@ -380,7 +827,7 @@ func TestArrayIndexExpressions(t *testing.T) {
 func TestHashLiterals(t *testing.T) {
 	// testing string, identifier, string, string, boolean, boolean as keys
 	input := `let two = "two";{"one": 10 - 9, two: 1 + 1, "thr" + "ee": 6 / 2, 4: 4, true: 5, false: 6}`
-	evaluated := evaluate(input)
+	evaluated := evaluate(t, input)
 
 	result, ok := evaluated.(*object.Hash)
 	if !ok {
@@ -392,8 +839,8 @@ func TestHashLiterals(t *testing.T) {
 		(&object.String{Value: "two"}).HashKey():   2,
 		(&object.String{Value: "three"}).HashKey(): 3,
 		(&object.Integer{Value: 4}).HashKey():      4,
-		True.HashKey():                             5,
-		False.HashKey():                            6,
+		eval.True.HashKey():                        5,
+		eval.False.HashKey():                       6,
 	}
 
 	if len(result.Pairs) != len(expected) {
@@ -409,6 +856,32 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"gohil"[0]`, "g"},
+		{`"çay 🍵"[4]`, "🍵"},
+		{`let şehir = "İzmir"; şehir[len(şehir) - 1]`, "r"},
+		{`"gohil"[5]`, nil},
+		{`"gohil"[-1]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := evaluate(t, tt.input)
+		if tt.expected == nil {
+			verifyNullObject(t, evaluated)
+			continue
+		}
+
+		str, ok := evaluated.(*object.String)
+		if !ok || str.Value != tt.expected {
+			t.Errorf("%s: expected %q, but got %v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -444,7 +917,7 @@ func TestHashIndexExpressions(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		evaluated := evaluate(tt.input)
+		evaluated := evaluate(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			verifyIntegerObj(t, evaluated, integer)
@@ -454,16 +927,109 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
-func evaluate(input string) object.Object {
+// evaluate runs the input with both backends, the tree-walking evaluator and the virtual machine.
+// The backends must agree on the result, the result of the evaluator is returned.
+func evaluate(t *testing.T, input string) object.Object {
+	t.Helper()
+
 	l := lexer.NewLexer(input)
 	p := parser.NewParser(l)
 	program := p.ParseProgram()
 	// new env for each test case, so as not to persist the previous state
-	environment := object.NewEnvironment()
-	obj := Eval(program, environment)
+	obj := eval.Eval(program, object.NewEnvironment())
+
+	c := compiler.NewCompiler()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compilation of %q failed: %v", input, err)
+	}
+	machine := vm.New(c.Bytecode(), object.NewEnvironment())
+	if vmObj := machine.Run(); !sameObject(obj, vmObj) {
+		t.Errorf("backends disagree on %q: eval=%s, vm=%s", input, describe(obj), describe(vmObj))
+	}
+
 	return obj
 }
 
+// sameObject compares results of the two backends,
+// functions are compared by their source as their representation differs
+func sameObject(expected object.Object, actual object.Object) bool {
+	return sameObjectIn(expected, actual, make(map[object.Object]bool))
+}
+
+// sameObjectIn compares the objects inside of the arrays and hashes that are being compared,
+// an array or a hash that contains itself is compared once
+func sameObjectIn(expected object.Object, actual object.Object, comparing map[object.Object]bool) bool {
+	if expected == nil {
+		expected = eval.Null
+	}
+	if actual == nil {
+		actual = eval.Null
+	}
+
+	if expected.Type() != actual.Type() {
+		return false
+	}
+
+	switch expected.(type) {
+	case *object.Array, *object.Hash:
+		if comparing[expected] {
+			return true
+		}
+		comparing[expected] = true
+		defer delete(comparing, expected)
+	}
+
+	switch expected := expected.(type) {
+	case *object.Error:
+		actual := actual.(*object.Error)
+		return expected.Message == actual.Message && expected.Pos == actual.Pos &&
+			describeStack(expected.Stack) == describeStack(actual.Stack)
+	case *object.Array:
+		actual := actual.(*object.Array)
+		if len(expected.Elements) != len(actual.Elements) {
+			return false
+		}
+		for i := range expected.Elements {
+			if !sameObjectIn(expected.Elements[i], actual.Elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		actual := actual.(*object.Hash)
+		if len(expected.Pairs) != len(actual.Pairs) {
+			return false
+		}
+		for key, pair := range expected.Pairs {
+			actualPair, ok := actual.Pairs[key]
+			if !ok || !sameObjectIn(pair.Value, actualPair.Value, comparing) {
+				return false
+			}
+		}
+		return true
+	default:
+		return expected.Inspect() == actual.Inspect()
+	}
+}
+
+func describe(obj object.Object) string {
+	if errObj, ok := obj.(*object.Error); ok {
+		return fmt.Sprintf("%s at %s %s", errObj.Message, errObj.Pos, describeStack(errObj.Stack))
+	}
+	if obj == nil {
+		return "nil"
+	}
+	return obj.Inspect()
+}
+
+func describeStack(stack []object.Frame) string {
+	var frames []string
+	for _, frame := range stack {
+		frames = append(frames, frame.Function+" "+frame.Pos.String())
+	}
+	return fmt.Sprintf("%v", frames)
+}
+
 func verifyBooleanObj(t *testing.T, obj object.Object, expected bool) {
 	val, ok := obj.(*object.Boolean)
 	if !ok {
@@ -485,7 +1051,180 @@ func verifyIntegerObj(t *testing.T, obj object.Object, expected int) {
 }
 
 func verifyNullObject(t *testing.T, obj object.Object) {
-	if obj != Null {
+	if obj != eval.Null {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
 	}
 }
+
+func TestErrorPositionAndStack(t *testing.T) {
+	input := `let inner = fn(a) {
+  a + x
+};
+let outer = fn(a) {
+  inner(a)
+};
+outer(1);`
+
+	evaluated := evaluate(t, input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("expected object of type Error, but got %T", evaluated)
+	}
+
+	if errObj.Pos.String() != "2:7" {
+		t.Errorf("expected error position 2:7, but got %s", errObj.Pos)
+	}
+
+	expectedStack := []string{"outer 7:1", "inner 5:3"}
+	var stack []string
+	for _, frame := range errObj.Stack {
+		stack = append(stack, frame.Function+" "+frame.Pos.String())
+	}
+	if !reflect.DeepEqual(stack, expectedStack) {
+		t.Errorf("expected stack %v, but got %v", expectedStack, stack)
+	}
+
+	expectedTraceback := `ERROR: identifier not found: x
+    at inner (script.ghl:2:7)
+    at outer (script.ghl:5:3)
+    at <main> (script.ghl:7:1)`
+	if traceback := errObj.Traceback("script.ghl"); traceback != expectedTraceback {
+		t.Errorf("expected traceback:\n%s\nbut got:\n%s", expectedTraceback, traceback)
+	}
+}
+
+func TestWrongNumberOfFunctionArguments(t *testing.T) {
+	evaluated := evaluate(t, "let add = fn(a, b) { a + b }; add(1);")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("expected object of type Error, but got %T", evaluated)
+	}
+
+	expected := "wrong number of arguments. got=1, want=2"
+	if errObj.Message != expected {
+		t.Errorf("expected error msg %v, but got %v", expected, errObj.Message)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-expired.Done()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input           string
+		ctx             context.Context
+		limits          eval.Limits
+		expectedMessage string
+	}{
+		{"let f = fn() { f() }; f()", context.Background(), eval.Limits{}, eval.StackDepthMessage},
+		{"let f = fn(n) { if (n > 0) { f(n - 1) } }; f(100)", context.Background(), eval.Limits{MaxDepth: 50}, eval.StackDepthMessage},
+		{"let f = fn(n) { f(n + 1) }; f(0)", context.Background(), eval.Limits{MaxSteps: 1000}, eval.StepLimitMessage},
+		{"while (true) { }", context.Background(), eval.Limits{MaxSteps: 1000}, eval.StepLimitMessage},
+		{"1 + 2", cancelled, eval.Limits{}, eval.CancelledMessage},
+		{"1 + 2", expired, eval.Limits{}, eval.TimeoutMessage},
+	}
+
+	for _, tt := range tests {
+		program := parser.NewParser(lexer.NewLexer(tt.input)).ParseProgram()
+
+		c := compiler.NewCompiler()
+		if err := c.Compile(program); err != nil {
+			t.Fatalf("compilation of %q failed: %v", tt.input, err)
+		}
+
+		results := map[string]object.Object{
+			"eval": eval.EvalContext(tt.ctx, program, object.NewEnvironment(), tt.limits),
+			"vm":   vm.New(c.Bytecode(), object.NewEnvironment()).RunContext(tt.ctx, tt.limits),
+		}
+		for backend, result := range results {
+			errObj, ok := result.(*object.Error)
+			if !ok {
+				t.Errorf("%s: %q: expected an error, but got %T", backend, tt.input, result)
+				continue
+			}
+			if errObj.Message != tt.expectedMessage {
+				t.Errorf("%s: %q: expected error %q, but got %q", backend, tt.input, tt.expectedMessage, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestLimitsAllowWithinBudget(t *testing.T) {
+	program := parser.NewParser(lexer.NewLexer("let f = fn(n) { if (n > 0) { f(n - 1) } else { 7 } }; f(10)")).ParseProgram()
+
+	result := eval.EvalContext(context.Background(), program, object.NewEnvironment(), eval.Limits{MaxSteps: 1000, MaxDepth: 11})
+	verifyIntegerObj(t, result, 7)
+}
+
+func TestTrace(t *testing.T) {
+	var logs bytes.Buffer
+	previous := logger.StdLog
+	logger.StdLog = logrus.New()
+	logger.StdLog.SetOutput(&logs)
+	logger.StdLog.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true, DisableQuote: true})
+	defer func() { logger.StdLog = previous }()
+
+	input := "let add = fn(a, b) { a + b }; let twice = fn(x) { add(x, x) }; twice(2)"
+	program := parser.NewParser(lexer.NewLexer(input)).ParseProgram()
+
+	tests := []struct {
+		level    logrus.Level
+		trace    eval.Trace
+		expected []string
+	}{
+		{
+			level: logrus.DebugLevel,
+			expected: []string{
+				"msg=Program let add",
+				"msg=  LetStmt let add = fn(a, b) (a + b);",
+				"msg=    => Function fn(a, b) { (a + b) }",
+				"msg=      call twice(Integer 2)",
+				"msg=              call add(Integer 2, Integer 2)",
+				"msg=              return add => Integer 4",
+				"msg==> Integer 4",
+			},
+		},
+		{
+			level: logrus.DebugLevel,
+			trace: eval.Trace{Functions: []string{"add"}},
+			expected: []string{
+				"msg=call add(Integer 2, Integer 2)",
+				"msg=  BlockStmt (a + b)",
+				"msg=        Identifier a",
+				"msg=return add => Integer 4",
+			},
+		},
+		{level: logrus.InfoLevel},
+	}
+
+	for _, tt := range tests {
+		logs.Reset()
+		logger.StdLog.SetLevel(tt.level)
+		ctx := eval.WithTrace(logger.PutLoggerInContext(context.Background()), tt.trace)
+
+		if result := eval.EvalContext(ctx, program, object.NewEnvironment(), eval.Limits{}); result.Inspect() != "4" {
+			t.Fatalf("expected 4, but got %s", result.Inspect())
+		}
+
+		lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+		if len(tt.expected) == 0 && logs.Len() > 0 {
+			t.Errorf("expected no trace, but got %q", logs.String())
+		}
+		if len(tt.expected) > 0 && len(tt.trace.Functions) > 0 && len(lines) != 12 {
+			t.Errorf("expected only the call of add to be traced, but got %q", logs.String())
+		}
+
+		rest := logs.String()
+		for _, expected := range tt.expected {
+			i := strings.Index(rest, expected)
+			if i < 0 {
+				t.Fatalf("expected %q in the rest of the trace %q", expected, rest)
+			}
+			rest = rest[i+len(expected):]
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package eval
+
+import (
+	"context"
+
+	"github.com/HakanSunay/gohil/object"
+)
+
+// DefaultMaxDepth is the maximum depth of the call stack, when Limits do not specify it.
+// Without a limit an infinite recursion would exhaust the Go stack and crash the process.
+const DefaultMaxDepth = 4096
+
+// The messages of the errors that abort an execution
+const (
+	CancelledMessage  = "execution cancelled"
+	TimeoutMessage    = "execution timed out"
+	StepLimitMessage  = "step limit exceeded"
+	StackDepthMessage = "stack depth exceeded"
+)
+
+// contextCheckInterval is the number of steps between two checks of the context,
+// checking it on every step would slow down the execution considerably
+const contextCheckInterval = 256
+
+// Limits bound the resources that a single execution can use
+type Limits struct {
+	// MaxSteps is the maximum number of steps (evaluated nodes or executed instructions), 0 means unlimited
+	MaxSteps int
+	// MaxDepth is the maximum depth of the call stack, 0 means DefaultMaxDepth
+	MaxDepth int
+}
+
+// Budget keeps track of the resources used by an execution.
+// It is shared by the backends, so that they abort executions in the same way.
+type Budget struct {
+	ctx    context.Context
+	limits Limits
+	steps  int
+}
+
+// NewBudget is the constructor for the Budget type
+func NewBudget(ctx context.Context, limits Limits) *Budget {
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = DefaultMaxDepth
+	}
+
+	return &Budget{ctx: ctx, limits: limits}
+}
+
+// Step counts a single step of the execution.
+// It returns an error if the step limit is exceeded or the context is done.
+func (b *Budget) Step() *object.Error {
+	b.steps++
+
+	if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+		return newError(StepLimitMessage)
+	}
+
+	if (b.steps-1)%contextCheckInterval == 0 {
+		return b.checkContext()
+	}
+
+	return nil
+}
+
+// Steps returns the number of steps taken so far
+func (b *Budget) Steps() int {
+	return b.steps
+}
+
+// EnterCall returns an error if a call at the given depth would exceed the maximum depth
+func (b *Budget) EnterCall(depth int) *object.Error {
+	if depth >= b.limits.MaxDepth {
+		return newError(StackDepthMessage)
+	}
+
+	return nil
+}
+
+func (b *Budget) checkContext() *object.Error {
+	switch b.ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return newError(TimeoutMessage)
+	default:
+		return newError(CancelledMessage)
+	}
+}
@@ -0,0 +1,39 @@
+package eval
+
+import "github.com/HakanSunay/gohil/object"
+
+// The operations below are the semantics of the language that do not depend on the syntax tree.
+// They are exported so that other backends (e.g. the virtual machine) behave exactly like Eval.
+
+// Prefix applies the prefix operator (! or -) to the operand
+func Prefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// Infix applies the infix operator to the operands
+func Infix(operator string, left object.Object, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// Index returns the element of the array or hash at the given index
+func Index(left object.Object, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// SetIndex replaces the element of the array or hash at the given index with the value,
+// it returns the value or an error
+func SetIndex(left object.Object, index object.Object, value object.Object) object.Object {
+	return evalSetIndex(left, index, value)
+}
+
+// Iterate returns the values that a for loop over the iterable binds to its variable:
+// the elements of an array, the keys of a hash or the characters of a string
+func Iterate(iterable object.Object) ([]object.Object, *object.Error) {
+	return iterate(iterable)
+}
+
+// IsTruthy reports whether the object counts as true in conditions,
+// everything except null and false is truthy
+func IsTruthy(obj object.Object) bool {
+	return obj != Null && obj != False
+}
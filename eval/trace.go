@@ -0,0 +1,134 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/HakanSunay/gohil/logger"
+	"github.com/HakanSunay/gohil/object"
+	"github.com/HakanSunay/gohil/syntaxtree"
+)
+
+// maxTracedText is the number of characters of the source text and the values that are traced,
+// the rest is left out to keep the entries readable
+const maxTracedText = 80
+
+// Trace configures the tracing of evaluations, which logs every evaluated node with its result
+// and every function call with its arguments and return value
+type Trace struct {
+	// Functions limits the tracing to the calls of the functions with these names,
+	// including everything they evaluate. Everything is traced when it is empty.
+	Functions []string
+}
+
+type traceKey struct{}
+
+// WithTrace enables the tracing of the evaluations that run with the returned context.
+// Entries are logged at debug level with the logger of the context, see logger.GetFromContext.
+// Only the tree-walking evaluator is traced.
+func WithTrace(ctx context.Context, trace Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// tracer logs an evaluation, every entry is indented by the depth of the node or call
+type tracer struct {
+	log       logrus.FieldLogger
+	functions map[string]bool
+
+	// depth is the number of nodes and calls that are being evaluated
+	depth int
+	// active is the number of traced functions that are being called,
+	// base is the depth of the outermost one, it is not indented
+	active int
+	base   int
+}
+
+// newTracer returns nil, unless tracing is enabled in the context and the logger would log it
+func newTracer(ctx context.Context) *tracer {
+	trace, ok := ctx.Value(traceKey{}).(Trace)
+	if !ok {
+		return nil
+	}
+
+	log := logger.GetFromContext(ctx)
+	if l, ok := log.(*logrus.Logger); ok && !l.IsLevelEnabled(logrus.DebugLevel) {
+		return nil
+	}
+
+	t := &tracer{log: log, functions: make(map[string]bool)}
+	for _, name := range trace.Functions {
+		t.functions[name] = true
+	}
+
+	return t
+}
+
+// enabled reports whether the current node or call is traced
+func (t *tracer) enabled() bool {
+	return len(t.functions) == 0 || t.active > 0
+}
+
+func (t *tracer) logf(format string, args ...interface{}) {
+	if t.enabled() {
+		t.log.Debugf(strings.Repeat("  ", t.depth-t.base)+format, args...)
+	}
+}
+
+func (t *tracer) enterNode(node syntaxtree.Node) {
+	t.logf("%s %s", strings.TrimPrefix(fmt.Sprintf("%T", node), "*syntaxtree."), abbreviate(node.String()))
+	t.depth++
+}
+
+func (t *tracer) exitNode(result object.Object) {
+	t.depth--
+	t.logf("=> %s", describe(result))
+}
+
+func (t *tracer) enterCall(name string, args []object.Object) {
+	if t.functions[name] {
+		if t.active == 0 {
+			t.base = t.depth
+		}
+		t.active++
+	}
+
+	described := make([]string, len(args))
+	for i, arg := range args {
+		described[i] = describe(arg)
+	}
+
+	t.logf("call %s(%s)", name, strings.Join(described, ", "))
+	t.depth++
+}
+
+func (t *tracer) exitCall(name string, result object.Object) {
+	t.depth--
+	t.logf("return %s => %s", name, describe(result))
+
+	if t.functions[name] {
+		t.active--
+	}
+}
+
+// describe returns the type and the value of the object
+func describe(obj object.Object) string {
+	if obj == nil {
+		return "no value"
+	}
+
+	return fmt.Sprintf("%s %s", obj.Type(), abbreviate(obj.Inspect()))
+}
+
+// abbreviate shortens the text to a single line of at most maxTracedText characters
+func abbreviate(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+
+	if runes := []rune(text); len(runes) > maxTracedText {
+		return string(runes[:maxTracedText-3]) + "..."
+	}
+
+	return text
+}
@@ -0,0 +1,296 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/HakanSunay/gohil/compiler"
+	"github.com/HakanSunay/gohil/convert"
+	"github.com/HakanSunay/gohil/eval"
+	"github.com/HakanSunay/gohil/lexer"
+	"github.com/HakanSunay/gohil/object"
+	"github.com/HakanSunay/gohil/parser"
+	"github.com/HakanSunay/gohil/syntaxtree"
+	"github.com/HakanSunay/gohil/vm"
+)
+
+// Backend selects how programs are executed
+type Backend string
+
+const (
+	// BackendEval walks the syntax tree, it is the default backend
+	BackendEval Backend = "eval"
+	// BackendVM compiles programs to bytecode and runs them on the virtual machine
+	BackendVM Backend = "vm"
+)
+
+// ParseBackend returns the backend with the given name
+func ParseBackend(name string) (Backend, error) {
+	switch backend := Backend(name); backend {
+	case BackendEval, BackendVM:
+		return backend, nil
+	default:
+		return "", fmt.Errorf("unknown backend %q, expected %q or %q", name, BackendEval, BackendVM)
+	}
+}
+
+// Interpreter is the entry point for Go programs that embed gohil.
+// Every interpreter owns its root environment, therefore the globals defined by one
+// program are visible to the next programs that are run by the same interpreter.
+// An Interpreter is not safe for concurrent use.
+type Interpreter struct {
+	environment *object.Environment
+	backend     Backend
+	limits      eval.Limits
+	timeout     time.Duration
+	// trace is nil, unless the evaluations are traced
+	trace *eval.Trace
+
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Option configures an Interpreter
+type Option func(*Interpreter)
+
+// WithStdout redirects the output of the print builtin
+func WithStdout(writer io.Writer) Option {
+	return func(i *Interpreter) {
+		i.stdout = writer
+	}
+}
+
+// WithStderr redirects the output of the eprint builtin
+func WithStderr(writer io.Writer) Option {
+	return func(i *Interpreter) {
+		i.stderr = writer
+	}
+}
+
+// WithBackend selects the backend that executes the programs of the interpreter.
+// Globals live in the root environment with both backends, functions are called with the backend
+// that created them.
+func WithBackend(backend Backend) Option {
+	return func(i *Interpreter) {
+		i.backend = backend
+	}
+}
+
+// WithLimits bounds the number of steps and the call depth of every Run and Call
+func WithLimits(limits eval.Limits) Option {
+	return func(i *Interpreter) {
+		i.limits = limits
+	}
+}
+
+// WithTimeout bounds the duration of every Run and Call, in addition to the deadline of their context
+func WithTimeout(timeout time.Duration) Option {
+	return func(i *Interpreter) {
+		i.timeout = timeout
+	}
+}
+
+// WithTrace logs every node evaluated by the tree-walking evaluator and every function call
+// at debug level, through the logger of the context, see eval.WithTrace
+func WithTrace(trace eval.Trace) Option {
+	return func(i *Interpreter) {
+		i.trace = &trace
+	}
+}
+
+// WithBuiltin registers a builtin function that is available only to this interpreter.
+// Builtins with the same name as the default ones take precedence over them.
+func WithBuiltin(name string, fn object.BuiltinFunction) Option {
+	return func(i *Interpreter) {
+		i.environment.Set(name, &object.Builtin{Fn: fn})
+	}
+}
+
+// WithGlobal binds the value to the given name in the root environment
+func WithGlobal(name string, value object.Object) Option {
+	return func(i *Interpreter) {
+		i.environment.Set(name, value)
+	}
+}
+
+// NewInterpreter is the constructor for the Interpreter type
+func NewInterpreter(opts ...Option) *Interpreter {
+	i := &Interpreter{
+		environment: object.NewEnvironment(),
+		backend:     BackendEval,
+		stdout:      os.Stdout,
+		stderr:      os.Stderr,
+	}
+
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	// the output builtins follow the configured writers, unless the host has replaced them
+	i.setDefault("print", eval.NewPrintBuiltin(i.stdout))
+	i.setDefault("eprint", eval.NewPrintBuiltin(i.stderr))
+
+	return i
+}
+
+// setDefault binds the value to the given name, if the name is not bound yet
+func (i *Interpreter) setDefault(name string, value object.Object) {
+	if _, ok := i.environment.Get(name); !ok {
+		i.environment.Set(name, value)
+	}
+}
+
+// Run parses and evaluates the given source code in the root environment of the interpreter.
+// Parse errors are returned as parser.ErrorList, runtime errors are returned as *object.Error.
+func (i *Interpreter) Run(ctx context.Context, src string) (object.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p := parser.NewParser(lexer.NewLexer(src))
+	program := p.ParseProgram()
+
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, parser.ErrorList(errs)
+	}
+
+	obj, err := i.Eval(ctx, program)
+	if err != nil {
+		return nil, err
+	}
+
+	if obj == nil {
+		return eval.Null, nil
+	}
+
+	return obj, nil
+}
+
+// Eval executes an already parsed program in the root environment of the interpreter.
+// Unlike Run, it returns a nil object for programs without a value (e.g. a let statement).
+// Runtime errors are returned as *object.Error, that includes the cancellation of the context
+// and exceeding the limits of the interpreter.
+func (i *Interpreter) Eval(ctx context.Context, program *syntaxtree.Program) (object.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := i.withTimeout(ctx)
+	defer cancel()
+
+	var obj object.Object
+	switch i.backend {
+	case BackendVM:
+		c := compiler.NewCompiler()
+		if err := c.Compile(program); err != nil {
+			return nil, err
+		}
+		obj = vm.New(c.Bytecode(), i.environment).RunContext(ctx, i.limits)
+	default:
+		obj = eval.EvalContext(ctx, program, i.environment, i.limits)
+	}
+
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, errObj
+	}
+
+	return obj, nil
+}
+
+// Call calls the function or builtin that is bound to the given name with the given arguments
+func (i *Interpreter) Call(fnName string, args ...object.Object) (object.Object, error) {
+	return i.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext is Call that is cancelled together with the context
+func (i *Interpreter) CallContext(ctx context.Context, fnName string, args ...object.Object) (object.Object, error) {
+	fn, ok := i.environment.Get(fnName)
+	if !ok {
+		builtin, ok := eval.LookupBuiltin(fnName)
+		if !ok {
+			return nil, fmt.Errorf("function not found: %s", fnName)
+		}
+		fn = builtin
+	}
+
+	ctx, cancel := i.withTimeout(ctx)
+	defer cancel()
+
+	switch fn.(type) {
+	case *object.Closure:
+		return result(vm.ApplyContext(ctx, i.limits, i.environment, fn, args...))
+	case *object.Function, *object.Builtin:
+		return result(eval.ApplyContext(ctx, i.limits, fnName, fn, args...))
+	default:
+		return nil, fmt.Errorf("not a function: %s is %s", fnName, fn.Type())
+	}
+}
+
+// Get returns the value of the global that is bound to the given name
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.environment.Get(name)
+}
+
+// Names returns the sorted names of the globals, including the output builtins
+func (i *Interpreter) Names() []string {
+	return i.environment.Names()
+}
+
+// Set binds the value to the given name in the root environment,
+// it is an error if the programs made the name a constant
+func (i *Interpreter) Set(name string, value object.Object) error {
+	if err := i.environment.Set(name, value); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetValue converts the Go value to a gohil object and binds it to the given name in the root environment
+func (i *Interpreter) SetValue(name string, value interface{}) error {
+	obj, err := convert.ToObject(value)
+	if err != nil {
+		return fmt.Errorf("unable to convert %s: %v", name, err)
+	}
+
+	return i.Set(name, obj)
+}
+
+// RegisterFunc binds an arbitrary Go function as a builtin, see convert.Func for the supported signatures
+func (i *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	builtin, err := convert.Func(fn)
+	if err != nil {
+		return fmt.Errorf("unable to register %s: %v", name, err)
+	}
+
+	return i.Set(name, builtin)
+}
+
+// withTimeout applies the timeout and the tracing of the interpreter to the context, if there are any
+func (i *Interpreter) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if i.trace != nil {
+		ctx = eval.WithTrace(ctx, *i.trace)
+	}
+
+	if i.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, i.timeout)
+}
+
+// result converts an evaluation result to the (object, error) pair returned to the host
+func result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, errObj
+	}
+
+	if obj == nil {
+		return eval.Null, nil
+	}
+
+	return obj, nil
+}
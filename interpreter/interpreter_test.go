@@ -0,0 +1,246 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HakanSunay/gohil/eval"
+	"github.com/HakanSunay/gohil/object"
+	"github.com/HakanSunay/gohil/parser"
+)
+
+func TestRun(t *testing.T) {
+	i := NewInterpreter()
+
+	result, err := i.Run(context.Background(), "let add = fn(a, b) { a + b }; add(2, 3)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	verifyInteger(t, result, 5)
+
+	// the globals of the previous run are still available
+	result, err = i.Run(context.Background(), "add(4, 5)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	verifyInteger(t, result, 9)
+}
+
+func TestBackends(t *testing.T) {
+	for _, backend := range []Backend{BackendEval, BackendVM} {
+		var stdout bytes.Buffer
+		i := NewInterpreter(WithBackend(backend), WithStdout(&stdout))
+
+		result, err := i.Run(context.Background(), `let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; print(fact(5)); fact(6)`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", backend, err)
+		}
+		verifyInteger(t, result, 720)
+
+		if stdout.String() != "120\n" {
+			t.Errorf("%s: unexpected output %q", backend, stdout.String())
+		}
+
+		result, err = i.Call("fact", &object.Integer{Value: 4})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", backend, err)
+		}
+		verifyInteger(t, result, 24)
+
+		_, err = i.Run(context.Background(), "\nfact(true)")
+		errObj, ok := err.(*object.Error)
+		if !ok {
+			t.Fatalf("%s: expected *object.Error, but got %T (%v)", backend, err, err)
+		}
+		if errObj.Pos.String() != "1:24" || len(errObj.Stack) != 1 || errObj.Stack[0].Pos.String() != "2:1" {
+			t.Errorf("%s: unexpected error %q at %s with stack %+v", backend, errObj.Message, errObj.Pos, errObj.Stack)
+		}
+	}
+
+	if _, err := ParseBackend("jit"); err == nil {
+		t.Errorf("expected an error for an unknown backend")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for _, backend := range []Backend{BackendEval, BackendVM} {
+		i := NewInterpreter(WithBackend(backend), WithLimits(eval.Limits{MaxSteps: 10000, MaxDepth: 100}))
+		if _, err := i.Run(context.Background(), "let loop = fn(n) { loop(n + 1) };"); err != nil {
+			t.Fatalf("%s: unexpected error: %v", backend, err)
+		}
+
+		_, err := i.Run(context.Background(), "loop(0)")
+		if errObj, ok := err.(*object.Error); !ok || errObj.Message != eval.StackDepthMessage {
+			t.Errorf("%s: expected %q, but got %v", backend, eval.StackDepthMessage, err)
+		}
+
+		_, err = i.Call("loop", &object.Integer{Value: 0})
+		if errObj, ok := err.(*object.Error); !ok || errObj.Message != eval.StackDepthMessage {
+			t.Errorf("%s: expected %q, but got %v", backend, eval.StackDepthMessage, err)
+		}
+
+		i = NewInterpreter(WithBackend(backend), WithTimeout(10*time.Millisecond))
+		_, err = i.Run(context.Background(), "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(40)")
+		if errObj, ok := err.(*object.Error); !ok || errObj.Message != eval.TimeoutMessage {
+			t.Errorf("%s: expected %q, but got %v", backend, eval.TimeoutMessage, err)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	i := NewInterpreter()
+
+	_, err := i.Run(context.Background(), "let = 5;")
+	if _, ok := err.(parser.ErrorList); !ok {
+		t.Errorf("expected parser.ErrorList, but got %T (%v)", err, err)
+	}
+
+	_, err = i.Run(context.Background(), "1 + true")
+	errObj, ok := err.(*object.Error)
+	if !ok {
+		t.Fatalf("expected *object.Error, but got %T (%v)", err, err)
+	}
+	if errObj.Message != "type mismatch: Integer + Boolean" {
+		t.Errorf("unexpected error message %q", errObj.Message)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = i.Run(ctx, "1"); err != context.Canceled {
+		t.Errorf("expected %v, but got %v", context.Canceled, err)
+	}
+}
+
+func TestOutputWriters(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	i := NewInterpreter(WithStdout(&stdout), WithStderr(&stderr))
+
+	if _, err := i.Run(context.Background(), `print("out"); eprint("err");`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stdout.String() != "out\n" {
+		t.Errorf("expected stdout %q, but got %q", "out\n", stdout.String())
+	}
+	if stderr.String() != "err\n" {
+		t.Errorf("expected stderr %q, but got %q", "err\n", stderr.String())
+	}
+}
+
+func TestBuiltinsAndGlobals(t *testing.T) {
+	double := func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	}
+
+	i := NewInterpreter(
+		WithBuiltin("double", double),
+		WithGlobal("base", &object.Integer{Value: 20}),
+	)
+	i.Set("offset", &object.Integer{Value: 2})
+
+	result, err := i.Run(context.Background(), "let answer = double(base) + offset; answer")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	verifyInteger(t, result, 42)
+
+	answer, ok := i.Get("answer")
+	if !ok {
+		t.Fatalf("expected answer to be bound")
+	}
+	verifyInteger(t, answer, 42)
+
+	// builtins of one interpreter are not visible to others
+	if _, err := NewInterpreter().Run(context.Background(), "double(1)"); err == nil {
+		t.Errorf("expected double to be unknown to a new interpreter")
+	}
+}
+
+func TestConstantsCannotBeSet(t *testing.T) {
+	for _, backend := range []Backend{BackendEval, BackendVM} {
+		i := NewInterpreter(WithBackend(backend))
+		if _, err := i.Run(context.Background(), "const limit = 10;"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := i.Set("limit", &object.Integer{Value: 20}); err == nil || err.Error() != "cannot redeclare constant limit" {
+			t.Errorf("%s: expected the constant not to be set, but got %v", backend, err)
+		}
+		if _, err := i.Run(context.Background(), "limit = 20"); err == nil {
+			t.Errorf("%s: expected the assignment of the constant to fail", backend)
+		}
+		limit, _ := i.Get("limit")
+		verifyInteger(t, limit, 10)
+	}
+}
+
+func TestCall(t *testing.T) {
+	i := NewInterpreter()
+	if _, err := i.Run(context.Background(), "let greet = fn(name) { \"Hello, \" + name }; let x = 1;"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result, err := i.Call("greet", &object.String{Value: "gohil"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if str, ok := result.(*object.String); !ok || str.Value != "Hello, gohil" {
+		t.Errorf("expected Hello, gohil, but got %v", result)
+	}
+
+	result, err = i.Call("len", &object.String{Value: "four"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	verifyInteger(t, result, 4)
+
+	if _, err := i.Call("missing"); err == nil {
+		t.Errorf("expected an error for a missing function")
+	}
+	if _, err := i.Call("x"); err == nil {
+		t.Errorf("expected an error for a non function")
+	}
+	if _, err := i.Call("greet"); err == nil {
+		t.Errorf("expected an error for wrong number of arguments")
+	}
+}
+
+func verifyInteger(t *testing.T, obj object.Object, expected int) {
+	t.Helper()
+
+	integer, ok := obj.(*object.Integer)
+	if !ok {
+		t.Fatalf("expected Integer object type, but got %T", obj)
+	}
+	if integer.Value != expected {
+		t.Errorf("expected %d, but got %d", expected, integer.Value)
+	}
+}
+
+func TestRegisterFuncAndSetValue(t *testing.T) {
+	i := NewInterpreter()
+
+	if err := i.RegisterFunc("greet", func(name string, times int) string {
+		return strings.Repeat("hi "+name+" ", times)
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := i.SetValue("config", map[string]interface{}{"name": "gohil", "times": 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result, err := i.Run(context.Background(), `greet(config["name"], config["times"])`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Inspect() != "hi gohil hi gohil " {
+		t.Errorf("unexpected result %q", result.Inspect())
+	}
+
+	if err := i.RegisterFunc("bad", 42); err == nil {
+		t.Errorf("expected an error for a non function")
+	}
+}
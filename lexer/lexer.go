@@ -1,7 +1,11 @@
 package lexer
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/HakanSunay/gohil/token"
 )
@@ -11,18 +15,87 @@ import (
 type Lexer struct {
 	input string
 
-	// supports only ASCII characters,
-	// for Unicode & UTF-8 we need to use rune,
-	// but that requires more complex logic for reading multi-byte chars
-	currentChar byte
+	// the input is UTF-8 encoded, therefore a character (rune) may take multiple bytes,
+	// currentIndex and nextIndex are byte offsets
+	currentChar rune
 
 	currentIndex int
 	nextIndex    int
+
+	// line and column of the current character, used for token positions,
+	// the column counts characters and not bytes
+	line   int
+	column int
+
+	// emitComments makes NextToken return comments as tokens, otherwise they are skipped
+	// and the ones before the last token are kept in comments
+	emitComments bool
+	comments     []token.Token
+
+	errors []*Error
+}
+
+// Option configures a lexer
+type Option func(*Lexer)
+
+// WithComments makes the lexer return comments as token.Comment tokens instead of skipping them,
+// which is needed by tools that preserve the comments, like formatters
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.emitComments = true
+	}
+}
+
+// Messages of the errors reported for strings and block comments that are not closed
+const (
+	UnterminatedString  = "unterminated string"
+	UnterminatedComment = "unterminated comment"
+)
+
+// Error describes a malformed token, which the lexer returns as a token.Illegal,
+// Pos is where the problem starts, e.g. the opening quote of an unterminated string
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+// Error renders the error in the <line>:<column>: <message> format
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// Errors returns the errors of the tokens that have been read so far
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+// Unterminated reports whether the input ends inside a string or a block comment, which is still open
+func (l *Lexer) Unterminated() bool {
+	errorCount := len(l.errors)
+	if errorCount == 0 {
+		return false
+	}
+
+	message := l.errors[errorCount-1].Message
+	return message == UnterminatedString || message == UnterminatedComment
+}
+
+// Comments returns the comments that were skipped before the token returned by the last NextToken call,
+// there are none when the lexer emits them as tokens
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+func (l *Lexer) addError(pos token.Position, message string) {
+	l.errors = append(l.errors, &Error{Pos: pos, Message: message})
 }
 
 // NewLexer initializes a new lexer type
-func NewLexer(input string) *Lexer {
-	l := &Lexer{input: input}
+func NewLexer(input string, options ...Option) *Lexer {
+	l := &Lexer{input: input, line: 1}
+	for _, option := range options {
+		option(l)
+	}
 
 	// this will initialize the fields of the lexer
 	l.nextChar()
@@ -33,30 +106,70 @@ func NewLexer(input string) *Lexer {
 // nextChar tries to read the next character for the input field,
 // if that is possible, it will update the rest of the fields accordingly
 func (l *Lexer) nextChar() {
-	// check if next byte is readable
+	// invalid UTF-8 is read as utf8.RuneError, one byte at a time
+	size := 1
 	if l.nextIndex >= len(l.input) {
 		// ASCII for NUL
 		l.currentChar = 0
 	} else {
-		l.currentChar = l.input[l.nextIndex]
+		l.currentChar, size = utf8.DecodeRuneInString(l.input[l.nextIndex:])
+	}
+
+	// keep track of the line and column of the char that is being read
+	if l.currentIndex < len(l.input) && l.input[l.currentIndex] == '\n' && l.column > 0 {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
 	}
 
 	// read next char and update field values
 	l.currentIndex = l.nextIndex
-	l.nextIndex++
+	l.nextIndex += size
+}
+
+// position returns the position of the current character
+func (l *Lexer) position() token.Position {
+	return token.Position{Offset: l.currentIndex, Line: l.line, Column: l.column}
 }
 
 // NextToken goes through the input string and extracts the tokens from it
 func (l *Lexer) NextToken() token.Token {
-	currentToken := token.Token{}
-
 	// major workaround, we are skipping whitespaces
 	// for languages like Python, they are necessary for scope definitions
 	l.eatWhitespace()
 
-	if unicode.IsDigit(rune(l.currentChar)) {
-		currentToken.Type = token.Int
-		currentToken.Literal = l.readNumber()
+	l.comments = nil
+	for l.currentChar == '/' && (l.peekNextChar() == '/' || l.peekNextChar() == '*') {
+		comment := l.readComment()
+		if l.emitComments || comment.Type == token.Illegal {
+			return comment
+		}
+
+		l.comments = append(l.comments, comment)
+		l.eatWhitespace()
+	}
+
+	start := l.position()
+	currentToken := l.readToken()
+
+	currentToken.Pos = start
+	if currentToken.Type == token.EOF {
+		// there is nothing after the end of the input
+		currentToken.End = start
+	} else {
+		currentToken.End = l.position()
+	}
+
+	return currentToken
+}
+
+// readToken reads the token that starts at the current character
+func (l *Lexer) readToken() token.Token {
+	currentToken := token.Token{}
+
+	if isDigit(l.currentChar) {
+		currentToken.Literal, currentToken.Type = l.readNumber()
 
 		return currentToken
 	}
@@ -86,9 +199,9 @@ func (l *Lexer) NextToken() token.Token {
 			currentToken.Set(token.Assign, l.currentChar)
 		}
 	case '+':
-		currentToken.Set(token.Plus, l.currentChar)
+		l.readOperator(&currentToken, token.Plus, '=', token.PlusAssign)
 	case '-':
-		currentToken.Set(token.Minus, l.currentChar)
+		l.readOperator(&currentToken, token.Minus, '=', token.MinusAssign)
 	case '!':
 		// not equal
 		if l.peekNextChar() == '=' {
@@ -100,13 +213,20 @@ func (l *Lexer) NextToken() token.Token {
 			currentToken.Set(token.ExclamationMark, l.currentChar)
 		}
 	case '/':
-		currentToken.Set(token.Slash, l.currentChar)
+		l.readOperator(&currentToken, token.Slash, '=', token.SlashAssign)
 	case '*':
-		currentToken.Set(token.Asterisk, l.currentChar)
+		l.readOperator(&currentToken, token.Asterisk, '=', token.AsteriskAssign)
+	case '%':
+		currentToken.Set(token.Percent, l.currentChar)
 	case '<':
-		currentToken.Set(token.LessThan, l.currentChar)
+		l.readOperator(&currentToken, token.LessThan, '=', token.LessEqual)
 	case '>':
-		currentToken.Set(token.GreaterThan, l.currentChar)
+		l.readOperator(&currentToken, token.GreaterThan, '=', token.GreaterEqual)
+	case '&':
+		// a single & is not an operator
+		l.readOperator(&currentToken, token.Illegal, '&', token.And)
+	case '|':
+		l.readOperator(&currentToken, token.Illegal, '|', token.Or)
 	case ';':
 		currentToken.Set(token.SemiColon, l.currentChar)
 	case '(':
@@ -125,9 +245,24 @@ func (l *Lexer) NextToken() token.Token {
 		currentToken.Set(token.RightBracket, l.currentChar)
 	case ':':
 		currentToken.Set(token.Colon, l.currentChar)
-	case '"':
+	case '"', '`':
+		startIndex := l.currentIndex
+
+		value, ok := l.readString()
+		if !ok {
+			// the string is unterminated, there is nothing after it
+			currentToken.Type = token.Illegal
+			currentToken.Literal = l.input[startIndex:]
+			return currentToken
+		}
+
 		currentToken.Type = token.String
-		currentToken.Literal = l.readString()
+		currentToken.Literal = value
+		if errorCount := len(l.errors); errorCount > 0 && l.errors[errorCount-1].Pos.Offset >= startIndex {
+			// the string has an invalid escape sequence
+			currentToken.Type = token.Illegal
+			currentToken.Literal = l.input[startIndex:l.nextIndex]
+		}
 	default:
 		currentToken.Set(token.Illegal, l.currentChar)
 	}
@@ -136,6 +271,20 @@ func (l *Lexer) NextToken() token.Token {
 	return currentToken
 }
 
+// readOperator reads an operator of one character (single), or of two characters,
+// when the current character is followed by second, like < and <=
+func (l *Lexer) readOperator(currentToken *token.Token, single token.Type, second rune, double token.Type) {
+	if l.peekNextChar() != second {
+		currentToken.Set(single, l.currentChar)
+		return
+	}
+
+	ch := l.currentChar
+	l.nextChar()
+	currentToken.Type = double
+	currentToken.Literal = string(ch) + string(l.currentChar)
+}
+
 // eatWhitespace is found in a lot of parsers.
 // Mostly known as (eat/consume/skip/ignore)Whitespace
 // We have decided to rely on the unicode library to choose the actual characters for us.
@@ -143,28 +292,101 @@ func (l *Lexer) NextToken() token.Token {
 // Some interpreters have tokens for newline characters as well, but we will skip that.
 func (l *Lexer) eatWhitespace() {
 	// keep on reading till non-whitespace character is hit
-	for unicode.IsSpace(rune(l.currentChar)) {
+	for unicode.IsSpace(l.currentChar) {
+		l.nextChar()
+	}
+}
+
+// readComment reads a line comment, which ends before the end of the line,
+// or a block comment, which can span multiple lines and ends with */.
+// The literal of the comment includes its delimiters. A block comment that is not closed
+// is returned as a token.Illegal, that runs to the end of the input.
+func (l *Lexer) readComment() token.Token {
+	start := l.position()
+	comment := token.Token{Type: token.Comment, Pos: start}
+
+	if l.peekNextChar() == '/' {
+		for l.currentChar != '\n' && !l.atEnd() {
+			l.nextChar()
+		}
+	} else {
+		// skip /* so that /*/ does not close the comment
+		l.nextChar()
 		l.nextChar()
+		for !(l.currentChar == '*' && l.peekNextChar() == '/') {
+			if l.atEnd() {
+				l.addError(start, UnterminatedComment)
+				comment.Type = token.Illegal
+				break
+			}
+			l.nextChar()
+		}
+
+		if comment.Type == token.Comment {
+			l.nextChar()
+			l.nextChar()
+		}
 	}
+
+	comment.Literal = l.input[start.Offset:l.currentIndex]
+	comment.End = l.position()
+	return comment
 }
 
-// readNumber currently supports only integer values.
-// This can be extended to support floats, hexadecimal and octal notation,
+// readNumber reads integer and float values, floats have a fraction, an exponent or both:
+// 42, 3.14, 1e9, 2.5E-3.
+// This can be extended to support hexadecimal and octal notation,
 // or even complex numbers like GoLang does (1 + 4i).
-func (l *Lexer) readNumber() string {
+func (l *Lexer) readNumber() (string, token.Type) {
 	startIndex := l.currentIndex
+	typ := token.Int
+
+	l.readDigits()
+
+	// the dot must be followed by a digit, a fraction cannot be empty
+	if l.currentChar == '.' && isDigit(l.peekNextChar()) {
+		typ = token.Float
+		l.nextChar()
+		l.readDigits()
+	}
+
+	// the exponent must have digits as well, otherwise the e starts an identifier
+	if l.currentChar == 'e' || l.currentChar == 'E' {
+		exponentDigit := l.peekNextChar()
+		if exponentDigit == '+' || exponentDigit == '-' {
+			exponentDigit = l.peekChar(2)
+		}
 
+		if isDigit(exponentDigit) {
+			typ = token.Float
+			l.nextChar()
+			if l.currentChar == '+' || l.currentChar == '-' {
+				l.nextChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return l.input[startIndex:l.currentIndex], typ
+}
+
+func (l *Lexer) readDigits() {
 	// We can even override this IsDigit method to support Roman numerals,
-	for unicode.IsDigit(rune(l.currentChar)) {
+	for isDigit(l.currentChar) {
 		l.nextChar()
 	}
+}
 
-	return l.input[startIndex:l.currentIndex]
+// isDigit checks if the given character is a decimal digit,
+// numbers are written with ASCII digits only, like in GoLang.
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
 }
 
-// isLetter checks if the given character byte is an ASCII letter.
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z'
+// isLetter checks if the given character is a Unicode letter or an underscore,
+// which can start an identifier.
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
 // readIdentifier reads the identifier in the input field
@@ -173,16 +395,12 @@ func isLetter(ch byte) bool {
 func (l *Lexer) readIdentifier() string {
 	startIndex := l.currentIndex
 
-	// if we want to support identifiers such as
-	// foo_bar!_!@_!var7
-	// we need to override isLetter to accept those as letters.
-	//
-	// In GoLang identifiers must abide by the following rule:
+	// Like in GoLang identifiers must abide by the following rule:
 	// identifier = letter { letter | unicode_digit } .
 	// therefore always starting with a letter,
 	// whereas letter abides by:
 	// letter = unicode_letter | "_" .
-	for isLetter(l.currentChar) {
+	for isLetter(l.currentChar) || unicode.IsDigit(l.currentChar) {
 		l.nextChar()
 	}
 
@@ -190,24 +408,119 @@ func (l *Lexer) readIdentifier() string {
 }
 
 // peekNextChar takes a look at the next char in the input string
-func (l *Lexer) peekNextChar() byte {
-	if l.nextIndex >= len(l.input) {
+func (l *Lexer) peekNextChar() rune {
+	return l.peekChar(1)
+}
+
+// peekChar returns the character that is the given number of characters after the current one
+func (l *Lexer) peekChar(distance int) rune {
+	index := l.nextIndex
+	for ; distance > 1 && index < len(l.input); distance-- {
+		_, size := utf8.DecodeRuneInString(l.input[index:])
+		index += size
+	}
+
+	if index >= len(l.input) {
 		return 0
 	}
 
-	return l.input[l.nextIndex]
+	ch, _ := utf8.DecodeRuneInString(l.input[index:])
+	return ch
 }
 
-// readString reads the whole string starting and ending with (")
-// "....."
-func (l *Lexer) readString() string {
-	startIndex := l.currentIndex + 1
-	for {
-		l.nextChar()
-		if l.currentChar == '"' || l.currentChar == 0 {
-			break
+// readString reads a string literal, the current character is its opening quote.
+// Strings in double quotes ("...") can contain escape sequences, which are replaced by the characters
+// they stand for, raw strings in backticks (`...`) are taken as they are and can span multiple lines.
+// ok is false if the input ends before the closing quote.
+func (l *Lexer) readString() (value string, ok bool) {
+	start := l.position()
+	quote := l.currentChar
+
+	var builder strings.Builder
+	for l.nextChar(); l.currentChar != quote; {
+		switch {
+		case l.atEnd():
+			l.addError(start, UnterminatedString)
+			return "", false
+		case l.currentChar == '\\' && quote == '"':
+			l.readEscape(&builder)
+		default:
+			builder.WriteRune(l.currentChar)
+			l.nextChar()
 		}
 	}
 
-	return l.input[startIndex:l.currentIndex]
+	return builder.String(), true
+}
+
+// readEscape reads the escape sequence that starts at the current backslash
+// and writes the character it stands for, invalid escape sequences are reported as errors.
+// The current character is the one after the escape sequence afterwards.
+func (l *Lexer) readEscape(builder *strings.Builder) {
+	start := l.position()
+	l.nextChar()
+
+	switch l.currentChar {
+	case 'n':
+		builder.WriteByte('\n')
+	case 't':
+		builder.WriteByte('\t')
+	case 'r':
+		builder.WriteByte('\r')
+	case '"':
+		builder.WriteByte('"')
+	case '\\':
+		builder.WriteByte('\\')
+	case 'u':
+		l.readUnicodeEscape(start, builder)
+		return
+	default:
+		// the end of the input is reported as an unterminated string
+		if !l.atEnd() {
+			l.addError(start, fmt.Sprintf("unknown escape sequence \\%c", l.currentChar))
+		}
+		return
+	}
+
+	l.nextChar()
+}
+
+// readUnicodeEscape reads the code point of a \u{...} escape sequence, the current character is the u.
+// The code point is written with 1 to 6 hexadecimal digits, e.g. \u{1F600}.
+func (l *Lexer) readUnicodeEscape(start token.Position, builder *strings.Builder) {
+	l.nextChar()
+	if l.currentChar != '{' {
+		l.addError(start, `invalid escape sequence \u, expected \u{...}`)
+		return
+	}
+	l.nextChar()
+
+	digitsIndex := l.currentIndex
+	for isHexDigit(l.currentChar) {
+		l.nextChar()
+	}
+	digits := l.input[digitsIndex:l.currentIndex]
+
+	if l.currentChar != '}' {
+		l.addError(start, `invalid escape sequence \u{`+digits+`, expected \u{...}`)
+		return
+	}
+	l.nextChar()
+
+	codePoint, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(codePoint)) {
+		l.addError(start, fmt.Sprintf(`invalid code point \u{%s}`, digits))
+		return
+	}
+
+	builder.WriteRune(rune(codePoint))
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// atEnd reports whether the whole input has been read
+func (l *Lexer) atEnd() bool {
+	return l.currentIndex >= len(l.input)
 }
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/HakanSunay/gohil/token"
@@ -201,3 +202,256 @@ func TestLexerNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestLexerTokenPositions(t *testing.T) {
+	input := `let x = "ab";
+  x == 10
+`
+	expected := []struct {
+		tokenType token.Type
+		pos       token.Position
+		end       token.Position
+	}{
+		{token.Let, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.Identifier, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{token.Assign, token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.String, token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 12, Line: 1, Column: 13}},
+		{token.SemiColon, token.Position{Offset: 12, Line: 1, Column: 13}, token.Position{Offset: 13, Line: 1, Column: 14}},
+		{token.Identifier, token.Position{Offset: 16, Line: 2, Column: 3}, token.Position{Offset: 17, Line: 2, Column: 4}},
+		{token.Equal, token.Position{Offset: 18, Line: 2, Column: 5}, token.Position{Offset: 20, Line: 2, Column: 7}},
+		{token.Int, token.Position{Offset: 21, Line: 2, Column: 8}, token.Position{Offset: 23, Line: 2, Column: 10}},
+		{token.EOF, token.Position{Offset: 24, Line: 3, Column: 1}, token.Position{Offset: 24, Line: 3, Column: 1}},
+	}
+
+	l := NewLexer(input)
+	for i, exp := range expected {
+		tok := l.NextToken()
+		if tok.Type != exp.tokenType {
+			t.Fatalf("tests[%d] - expected token type %v, but got %v", i, exp.tokenType, tok.Type)
+		}
+		if tok.Pos != exp.pos {
+			t.Errorf("tests[%d] - expected start position %+v, but got %+v", i, exp.pos, tok.Pos)
+		}
+		if tok.End != exp.end {
+			t.Errorf("tests[%d] - expected end position %+v, but got %+v", i, exp.end, tok.End)
+		}
+	}
+}
+
+func TestLexerNumbers(t *testing.T) {
+	input := `42 3.14 1e9 2.5E-3 6e+2 7. 8e x1`
+	expected := []struct {
+		tokenType token.Type
+		literal   string
+	}{
+		{token.Int, "42"},
+		{token.Float, "3.14"},
+		{token.Float, "1e9"},
+		{token.Float, "2.5E-3"},
+		{token.Float, "6e+2"},
+		// a dot or an exponent without digits is not part of the number
+		{token.Int, "7"},
+		{token.Illegal, "."},
+		{token.Int, "8"},
+		{token.Identifier, "e"},
+		// digits can follow the first letter of an identifier
+		{token.Identifier, "x1"},
+	}
+
+	l := NewLexer(input)
+	for i, exp := range expected {
+		tok := l.NextToken()
+		if tok.Type != exp.tokenType || tok.Literal != exp.literal {
+			t.Fatalf("tests[%d] - expected %v %q, but got %v %q", i, exp.tokenType, exp.literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestLexerUnicode(t *testing.T) {
+	input := "let şehir_2 = \"İstanbul 🌉\"; _çay + 🍵\n٣"
+	expected := []struct {
+		tokenType token.Type
+		literal   string
+		pos       token.Position
+	}{
+		{token.Let, "let", token.Position{Offset: 0, Line: 1, Column: 1}},
+		{token.Identifier, "şehir_2", token.Position{Offset: 4, Line: 1, Column: 5}},
+		{token.Assign, "=", token.Position{Offset: 13, Line: 1, Column: 13}},
+		{token.String, "İstanbul 🌉", token.Position{Offset: 15, Line: 1, Column: 15}},
+		{token.SemiColon, ";", token.Position{Offset: 31, Line: 1, Column: 27}},
+		{token.Identifier, "_çay", token.Position{Offset: 33, Line: 1, Column: 29}},
+		{token.Plus, "+", token.Position{Offset: 39, Line: 1, Column: 34}},
+		// emoji are not letters and only ASCII digits start numbers
+		{token.Illegal, "🍵", token.Position{Offset: 41, Line: 1, Column: 36}},
+		{token.Illegal, "٣", token.Position{Offset: 46, Line: 2, Column: 1}},
+		{token.EOF, "\x00", token.Position{Offset: 48, Line: 2, Column: 2}},
+	}
+
+	l := NewLexer(input)
+	for i, exp := range expected {
+		tok := l.NextToken()
+		if tok.Type != exp.tokenType || tok.Literal != exp.literal || tok.Pos != exp.pos {
+			t.Fatalf("tests[%d] - expected %v %q at %+v, but got %v %q at %+v",
+				i, exp.tokenType, exp.literal, exp.pos, tok.Type, tok.Literal, tok.Pos)
+		}
+	}
+}
+
+func TestLexerStrings(t *testing.T) {
+	tests := []struct {
+		input     string
+		tokenType token.Type
+		literal   string
+		end       int
+	}{
+		{`"plain"`, token.String, "plain", 7},
+		{`"say \"hi\"\n\ttab \\ end"`, token.String, "say \"hi\"\n\ttab \\ end", 26},
+		{`"\u{48}\u{e9}\u{1F600}"`, token.String, "Hé😀", 23},
+		{"`raw \\n \"quoted\"\nsecond line`", token.String, "raw \\n \"quoted\"\nsecond line", 29},
+		{`"bad \q escape"`, token.Illegal, `"bad \q escape"`, 15},
+		{`"\u{110000}"`, token.Illegal, `"\u{110000}"`, 12},
+		{`"\u48"`, token.Illegal, `"\u48"`, 6},
+		{`"unterminated \"`, token.Illegal, `"unterminated \"`, 16},
+		{"`open\nraw", token.Illegal, "`open\nraw", 9},
+	}
+
+	for _, tt := range tests {
+		tok := NewLexer(tt.input).NextToken()
+		if tok.Type != tt.tokenType || tok.Literal != tt.literal || tok.End.Offset != tt.end {
+			t.Errorf("input %q: expected %v %q ending at %d, but got %v %q ending at %d",
+				tt.input, tt.tokenType, tt.literal, tt.end, tok.Type, tok.Literal, tok.End.Offset)
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input        string
+		expected     []string
+		unterminated bool
+	}{
+		{`"fine" + "\n"`, nil, false},
+		{`"a\qb" + "\u{zz}"`, []string{`1:3: unknown escape sequence \q`, `1:11: invalid escape sequence \u{, expected \u{...}`}, false},
+		{"let s = \"one\n two", []string{"1:9: unterminated string"}, true},
+		{"x + `raw", []string{"1:5: unterminated string"}, true},
+	}
+
+	for _, tt := range tests {
+		l := NewLexer(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		var errs []string
+		for _, err := range l.Errors() {
+			errs = append(errs, err.Error())
+		}
+		if !reflect.DeepEqual(errs, tt.expected) {
+			t.Errorf("input %q: expected errors %q, but got %q", tt.input, tt.expected, errs)
+		}
+		if l.Unterminated() != tt.unterminated {
+			t.Errorf("input %q: expected unterminated %t, but got %t", tt.input, tt.unterminated, l.Unterminated())
+		}
+	}
+}
+
+func TestLexerComments(t *testing.T) {
+	input := "// add adds\nlet add = 6 / 2; /* block\ncomment */ x //trailing"
+	expected := []struct {
+		tokenType token.Type
+		literal   string
+	}{
+		{token.Comment, "// add adds"},
+		{token.Let, "let"},
+		{token.Identifier, "add"},
+		{token.Assign, "="},
+		{token.Int, "6"},
+		{token.Slash, "/"},
+		{token.Int, "2"},
+		{token.SemiColon, ";"},
+		{token.Comment, "/* block\ncomment */"},
+		{token.Identifier, "x"},
+		{token.Comment, "//trailing"},
+		{token.EOF, "\x00"},
+	}
+
+	emitting := NewLexer(input, WithComments())
+	skipping := NewLexer(input)
+	for i, exp := range expected {
+		tok := emitting.NextToken()
+		if tok.Type != exp.tokenType || tok.Literal != exp.literal {
+			t.Fatalf("tests[%d] - expected %v %q, but got %v %q", i, exp.tokenType, exp.literal, tok.Type, tok.Literal)
+		}
+		if exp.tokenType == token.Comment {
+			continue
+		}
+
+		tok = skipping.NextToken()
+		if tok.Type != exp.tokenType || tok.Literal != exp.literal {
+			t.Fatalf("tests[%d] - expected %v %q without comments, but got %v %q", i, exp.tokenType, exp.literal, tok.Type, tok.Literal)
+		}
+		if comments := skipping.Comments(); i > 0 && expected[i-1].tokenType == token.Comment &&
+			(len(comments) != 1 || comments[0].Literal != expected[i-1].literal) {
+			t.Fatalf("tests[%d] - expected the comment %q before the token, but got %v", i, expected[i-1].literal, comments)
+		}
+	}
+}
+
+func TestLexerUnterminatedComment(t *testing.T) {
+	l := NewLexer("1 /* open\ncomment")
+	l.NextToken()
+
+	tok := l.NextToken()
+	if tok.Type != token.Illegal || tok.Literal != "/* open\ncomment" {
+		t.Errorf("expected the unterminated comment to be illegal, but got %v %q", tok.Type, tok.Literal)
+	}
+	if !l.Unterminated() || l.Errors()[0].Error() != "1:3: unterminated comment" {
+		t.Errorf("expected an unterminated comment error, but got %v", l.Errors())
+	}
+}
+
+func TestLexerOperators(t *testing.T) {
+	input := "a <= b >= c && d || e % f < g > h & i | j += k -= l *= m /= n = o"
+	expected := []struct {
+		tokenType token.Type
+		literal   string
+	}{
+		{token.Identifier, "a"},
+		{token.LessEqual, "<="},
+		{token.Identifier, "b"},
+		{token.GreaterEqual, ">="},
+		{token.Identifier, "c"},
+		{token.And, "&&"},
+		{token.Identifier, "d"},
+		{token.Or, "||"},
+		{token.Identifier, "e"},
+		{token.Percent, "%"},
+		{token.Identifier, "f"},
+		{token.LessThan, "<"},
+		{token.Identifier, "g"},
+		{token.GreaterThan, ">"},
+		{token.Identifier, "h"},
+		{token.Illegal, "&"},
+		{token.Identifier, "i"},
+		{token.Illegal, "|"},
+		{token.Identifier, "j"},
+		{token.PlusAssign, "+="},
+		{token.Identifier, "k"},
+		{token.MinusAssign, "-="},
+		{token.Identifier, "l"},
+		{token.AsteriskAssign, "*="},
+		{token.Identifier, "m"},
+		{token.SlashAssign, "/="},
+		{token.Identifier, "n"},
+		{token.Assign, "="},
+		{token.Identifier, "o"},
+		{token.EOF, "\x00"},
+	}
+
+	l := NewLexer(input)
+	for i, exp := range expected {
+		tok := l.NextToken()
+		if tok.Type != exp.tokenType || tok.Literal != exp.literal {
+			t.Fatalf("tests[%d] - expected %v %q, but got %v %q", i, exp.tokenType, exp.literal, tok.Type, tok.Literal)
+		}
+	}
+}
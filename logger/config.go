@@ -0,0 +1,273 @@
+package logger
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Special values of Config.File, every other value is the path of a log file
+const (
+	FileStderr   = "stderr"
+	FileDisabled = "off"
+)
+
+// Environment variables that configure the logging, they take precedence over the config file
+const (
+	EnvConfig     = "GOHIL_LOG_CONFIG"
+	EnvFile       = "GOHIL_LOG_FILE"
+	EnvLevel      = "GOHIL_LOG_LEVEL"
+	EnvMaxSize    = "GOHIL_LOG_MAX_SIZE"
+	EnvMaxBackups = "GOHIL_LOG_MAX_BACKUPS"
+	EnvMaxAge     = "GOHIL_LOG_MAX_AGE"
+	EnvFormat     = "GOHIL_LOG_FORMAT"
+	EnvCaller     = "GOHIL_LOG_CALLER"
+	EnvSessionID  = "GOHIL_LOG_SESSION_ID"
+)
+
+// Config describes where and what is logged.
+// Zero values are unset, they are taken from the configuration with the lower precedence.
+// The fields whose zero value is meaningful are pointers, so that an explicit zero is set.
+type Config struct {
+	// File is the path of the log file, which is rotated when it reaches MaxSizeMB,
+	// FileStderr logs to STDERR and FileDisabled turns the logging off
+	File  string `json:"file"`
+	Level string `json:"level"`
+
+	MaxSizeMB int `json:"max_size_mb"`
+	// MaxBackups is the number of rotated files that are kept, 0 keeps all of them
+	MaxBackups *int `json:"max_backups"`
+	// MaxAgeDays is the number of days after which rotated files are removed, 0 keeps them
+	MaxAgeDays *int `json:"max_age_days"`
+
+	// Format is FormatText, FormatJSON or FormatLogfmt
+	Format string `json:"format"`
+	// Caller adds the function, file and line that logged to every entry
+	Caller *bool `json:"caller"`
+	// SessionID is added to every entry, a random one is generated when it is not set
+	SessionID string `json:"session_id"`
+}
+
+// DefaultConfig logs at info level to a rotated file in the user cache directory,
+// or to STDERR when there is no such directory
+func DefaultConfig() Config {
+	file := FileStderr
+	if dir, err := os.UserCacheDir(); err == nil {
+		file = filepath.Join(dir, "gohil", "gohil.log")
+	}
+
+	maxBackups := defaultLogFileMaxBackups
+	return Config{
+		File:       file,
+		Level:      defaultLogLevel.String(),
+		MaxSizeMB:  defaultLogFileMaxSizeMB,
+		MaxBackups: &maxBackups,
+		Format:     FormatText,
+	}
+}
+
+// Override replaces the fields of the config with the fields that are set in other
+func (c *Config) Override(other Config) {
+	if other.File != "" {
+		c.File = other.File
+	}
+	if other.Level != "" {
+		c.Level = other.Level
+	}
+	if other.MaxSizeMB != 0 {
+		c.MaxSizeMB = other.MaxSizeMB
+	}
+	if other.MaxBackups != nil {
+		c.MaxBackups = other.MaxBackups
+	}
+	if other.MaxAgeDays != nil {
+		c.MaxAgeDays = other.MaxAgeDays
+	}
+	if other.Format != "" {
+		c.Format = other.Format
+	}
+	if other.Caller != nil {
+		c.Caller = other.Caller
+	}
+	if other.SessionID != "" {
+		c.SessionID = other.SessionID
+	}
+}
+
+// RegisterFlags defines the logging flags on the flag set, their values are stored in the config
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.File, "log-file", "", fmt.Sprintf("log file, %q or %q to turn the logging off (env %s)", FileStderr, FileDisabled, EnvFile))
+	fs.StringVar(&c.Level, "log-level", "", fmt.Sprintf("log level: trace, debug, info, warning, error, fatal or panic (env %s)", EnvLevel))
+	fs.IntVar(&c.MaxSizeMB, "log-max-size", 0, fmt.Sprintf("size in megabytes at which the log file is rotated (env %s)", EnvMaxSize))
+	fs.Var(intFlag{&c.MaxBackups}, "log-max-backups", fmt.Sprintf("number of rotated log files that are kept, 0 keeps all (env %s)", EnvMaxBackups))
+	fs.Var(intFlag{&c.MaxAgeDays}, "log-max-age", fmt.Sprintf("days after which rotated log files are removed, 0 keeps them (env %s)", EnvMaxAge))
+	fs.StringVar(&c.Format, "log-format", "", fmt.Sprintf("log format: %s, %s or %s (env %s)", FormatText, FormatJSON, FormatLogfmt, EnvFormat))
+	fs.Var(boolFlag{&c.Caller}, "log-caller", fmt.Sprintf("log the function, file and line of every entry (env %s)", EnvCaller))
+	fs.StringVar(&c.SessionID, "log-session-id", "", fmt.Sprintf("ID that correlates the entries of this run, random by default (env %s)", EnvSessionID))
+}
+
+// ReportCaller reports whether the function, file and line that logged are added to every entry
+func (c *Config) ReportCaller() bool {
+	return c.Caller != nil && *c.Caller
+}
+
+// boolFlag is a boolean flag that is only set when it is given, see Config.Caller
+type boolFlag struct {
+	value **bool
+}
+
+func (b boolFlag) String() string {
+	if b.value == nil || *b.value == nil {
+		return "false"
+	}
+
+	return strconv.FormatBool(**b.value)
+}
+
+func (b boolFlag) Set(text string) error {
+	value, err := strconv.ParseBool(text)
+	if err != nil {
+		return err
+	}
+
+	*b.value = &value
+	return nil
+}
+
+// IsBoolFlag allows the flag to be given without a value, e.g. -log-caller
+func (b boolFlag) IsBoolFlag() bool {
+	return true
+}
+
+// intFlag is an integer flag that is only set when it is given, see Config.MaxBackups
+type intFlag struct {
+	value **int
+}
+
+func (i intFlag) String() string {
+	if i.value == nil || *i.value == nil {
+		return "0"
+	}
+
+	return strconv.Itoa(**i.value)
+}
+
+func (i intFlag) Set(text string) error {
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return err
+	}
+
+	*i.value = &value
+	return nil
+}
+
+// intValue returns the value of an integer field of the config, 0 when it is not set
+func intValue(value *int) int {
+	if value == nil {
+		return 0
+	}
+
+	return *value
+}
+
+// LoadConfig combines the default config, the config file, the environment and the flags,
+// each of them takes precedence over the previous ones.
+// The config file is read from path, GOHIL_LOG_CONFIG or gohil/logging.json in the user config directory,
+// only the first of them that is set is used and the last one may be missing.
+func LoadConfig(path string, flags Config) (Config, error) {
+	config := DefaultConfig()
+
+	file, err := readConfigFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	config.Override(file)
+
+	env, err := configFromEnv(os.LookupEnv)
+	if err != nil {
+		return Config{}, err
+	}
+	config.Override(env)
+
+	config.Override(flags)
+	return config, nil
+}
+
+// readConfigFile reads the JSON config file, see LoadConfig for where it is looked up
+func readConfigFile(path string) (Config, error) {
+	optional := false
+	if path == "" {
+		path = os.Getenv(EnvConfig)
+	}
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return Config{}, nil
+		}
+		path, optional = filepath.Join(dir, "gohil", "logging.json"), true
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && optional {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to read the log config: %v", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("invalid log config %s: %v", path, err)
+	}
+
+	return config, nil
+}
+
+// configFromEnv reads the config from the environment variables
+func configFromEnv(lookup func(string) (string, bool)) (Config, error) {
+	var config Config
+	config.File, _ = lookup(EnvFile)
+	config.Level, _ = lookup(EnvLevel)
+	config.Format, _ = lookup(EnvFormat)
+	config.SessionID, _ = lookup(EnvSessionID)
+
+	if text, ok := lookup(EnvCaller); ok && text != "" {
+		caller, err := strconv.ParseBool(text)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %q is not a boolean", EnvCaller, text)
+		}
+		config.Caller = &caller
+	}
+
+	var maxSize *int
+	numbers := []struct {
+		name  string
+		value **int
+	}{
+		{EnvMaxSize, &maxSize},
+		{EnvMaxBackups, &config.MaxBackups},
+		{EnvMaxAge, &config.MaxAgeDays},
+	}
+	for _, number := range numbers {
+		text, ok := lookup(number.name)
+		if !ok || text == "" {
+			continue
+		}
+
+		value, err := strconv.Atoi(text)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %q is not a number", number.name, text)
+		}
+		*number.value = &value
+	}
+	if maxSize != nil {
+		config.MaxSizeMB = *maxSize
+	}
+
+	return config, nil
+}
@@ -0,0 +1,190 @@
+package logger
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		EnvFile:       FileStderr,
+		EnvLevel:      "debug",
+		EnvMaxBackups: "2",
+		EnvMaxAge:     "",
+		EnvFormat:     FormatLogfmt,
+		EnvCaller:     "true",
+		EnvSessionID:  "abc",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	config, err := configFromEnv(lookup)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	caller, maxBackups := true, 2
+	expected := Config{File: FileStderr, Level: "debug", MaxBackups: &maxBackups, Format: FormatLogfmt, Caller: &caller, SessionID: "abc"}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, config)
+	}
+
+	env[EnvMaxSize] = "big"
+	if _, err := configFromEnv(lookup); err == nil {
+		t.Errorf("expected an error for an invalid number")
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gohil")
+	if err != nil {
+		t.Fatalf("unable to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logging.json")
+	content := `{"file": "from-file.log", "level": "warning", "max_size_mb": 1, "max_age_days": 7, "format": "json"}`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unable to write the config: %v", err)
+	}
+
+	os.Setenv(EnvLevel, "error")
+	os.Setenv(EnvMaxSize, "2")
+	defer os.Unsetenv(EnvLevel)
+	defer os.Unsetenv(EnvMaxSize)
+
+	var flags Config
+	fs := flag.NewFlagSet("gohil", flag.ContinueOnError)
+	flags.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-log-level", "debug", "-log-caller"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	config, err := LoadConfig(path, flags)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	caller, maxBackups, maxAge := true, defaultLogFileMaxBackups, 7
+	expected := Config{
+		File:       "from-file.log",
+		Level:      "debug",
+		MaxSizeMB:  2,
+		MaxBackups: &maxBackups,
+		MaxAgeDays: &maxAge,
+		Format:     FormatJSON,
+		Caller:     &caller,
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, config)
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "missing.json"), Config{}); err == nil {
+		t.Errorf("expected an error for a missing config file")
+	}
+}
+
+func TestLoadConfigOverridesTrueWithFalse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gohil")
+	if err != nil {
+		t.Fatalf("unable to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logging.json")
+	if err := ioutil.WriteFile(path, []byte(`{"caller": true}`), 0644); err != nil {
+		t.Fatalf("unable to write the config: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		env      string
+		args     []string
+		expected bool
+	}{
+		{"file", "", nil, true},
+		{"env", "false", nil, false},
+		{"flag", "", []string{"-log-caller=false"}, false},
+		{"flag over env", "false", []string{"-log-caller"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				os.Setenv(EnvCaller, tt.env)
+				defer os.Unsetenv(EnvCaller)
+			}
+
+			var flags Config
+			fs := flag.NewFlagSet("gohil", flag.ContinueOnError)
+			flags.RegisterFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			config, err := LoadConfig(path, flags)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if config.ReportCaller() != tt.expected {
+				t.Errorf("expected the caller to be reported: %t, but got %t", tt.expected, config.ReportCaller())
+			}
+		})
+	}
+}
+
+func TestLoadConfigOverridesWithZero(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gohil")
+	if err != nil {
+		t.Fatalf("unable to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logging.json")
+	if err := ioutil.WriteFile(path, []byte(`{"max_backups": 3, "max_age_days": 7}`), 0644); err != nil {
+		t.Fatalf("unable to write the config: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		env        map[string]string
+		args       []string
+		maxBackups int
+		maxAge     int
+	}{
+		{"file", nil, nil, 3, 7},
+		{"env", map[string]string{EnvMaxBackups: "0", EnvMaxAge: "0"}, nil, 0, 0},
+		{"flag", nil, []string{"-log-max-backups", "0", "-log-max-age=0"}, 0, 0},
+		{"flag over env", map[string]string{EnvMaxBackups: "0"}, []string{"-log-max-backups", "4"}, 4, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+
+			var flags Config
+			fs := flag.NewFlagSet("gohil", flag.ContinueOnError)
+			flags.RegisterFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			config, err := LoadConfig(path, flags)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if intValue(config.MaxBackups) != tt.maxBackups || intValue(config.MaxAgeDays) != tt.maxAge {
+				t.Errorf("expected %d backups and %d days, but got %d and %d",
+					tt.maxBackups, tt.maxAge, intValue(config.MaxBackups), intValue(config.MaxAgeDays))
+			}
+		})
+	}
+}
@@ -3,6 +3,9 @@ package logger
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -10,11 +13,87 @@ import (
 
 const rfc3339Milli = "2006-01-02T15:04:05.999Z07:00"
 
+// Names of the log formats, see Config.Format
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// Keys of the fields that are added to every entry
+const (
+	FieldSession = "session"
+	FieldCaller  = "caller"
+)
+
+// newFormatter returns the formatter with the given name, which adds the session ID to every entry
+func newFormatter(format string, sessionID string) (logrus.Formatter, error) {
+	var formatter logrus.Formatter
+
+	switch format {
+	case "", FormatText:
+		formatter = &gohilLogFormatter{}
+	case FormatJSON:
+		formatter = &logrus.JSONFormatter{
+			TimestampFormat:  rfc3339Milli,
+			CallerPrettyfier: shortCaller,
+		}
+	case FormatLogfmt:
+		formatter = &logrus.TextFormatter{
+			DisableColors:    true,
+			FullTimestamp:    true,
+			TimestampFormat:  rfc3339Milli,
+			QuoteEmptyFields: true,
+			CallerPrettyfier: shortCaller,
+		}
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+
+	if sessionID == "" {
+		return formatter, nil
+	}
+
+	return &sessionFormatter{Formatter: formatter, sessionID: sessionID}, nil
+}
+
+// shortCaller leaves out the package path of the function and the directory of the file,
+// which are the same for every entry
+func shortCaller(frame *runtime.Frame) (function string, file string) {
+	return filepath.Base(frame.Function), fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+}
+
+// sessionFormatter adds the session ID to the fields of every entry,
+// so that the entries of a single run of gohil can be correlated
+type sessionFormatter struct {
+	logrus.Formatter
+	sessionID string
+}
+
+func (sf *sessionFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if entry == nil {
+		return nil, errors.New("logger entry is nil")
+	}
+
+	// the fields may be shared with other entries, they must not be modified
+	data := make(logrus.Fields, len(entry.Data)+1)
+	for key, value := range entry.Data {
+		data[key] = value
+	}
+	data[FieldSession] = sf.sessionID
+
+	withSession := *entry
+	withSession.Data = data
+
+	return sf.Formatter.Format(&withSession)
+}
+
 // gohilLogFormatter represents a log line formatter.
 type gohilLogFormatter struct{}
 
 // Format formats log entry to form a log line.
-// Line format <UTC RFC3339 formatted time> <LEVEL> <msg>
+// Line format <UTC RFC3339 formatted time> <LEVEL> <msg> [<key>=<value>...]
+// The fields are sorted by their keys, the caller is a field as well.
 func (gf *gohilLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	if entry == nil {
 		return nil, errors.New("logger entry is nil")
@@ -29,7 +108,40 @@ func (gf *gohilLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 
 	logLineData := entry.Buffer
 	logLineData.WriteString(
-		fmt.Sprintf("%s %s %s\n", time, level, entry.Message))
+		fmt.Sprintf("%s %s %s", time, level, entry.Message))
+
+	fields := make(map[string]interface{}, len(entry.Data)+1)
+	for key, value := range entry.Data {
+		fields[key] = value
+	}
+	if entry.HasCaller() {
+		_, fields[FieldCaller] = shortCaller(entry.Caller)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		logLineData.WriteString(fmt.Sprintf(" %s=%s", key, formatValue(fields[key])))
+	}
+	logLineData.WriteString("\n")
 
 	return logLineData.Bytes(), nil
 }
+
+// formatValue quotes the values that would be ambiguous in a key=value pair
+func formatValue(value interface{}) string {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+
+	text := fmt.Sprint(value)
+	if text == "" || strings.ContainsAny(text, " =\"\t\n") {
+		return fmt.Sprintf("%q", text)
+	}
+
+	return text
+}
@@ -2,6 +2,8 @@ package logger
 
 import (
 	"bytes"
+	"errors"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -61,3 +63,57 @@ func TestGohilLogFormatterFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestGohilLogFormatterFields(t *testing.T) {
+	entry := &logrus.Entry{
+		Level:   logrus.InfoLevel,
+		Message: "Evaluated",
+		Data:    logrus.Fields{"steps": 42, "backend": "vm", "error": errors.New("no way")},
+		Caller:  &runtime.Frame{Function: "github.com/HakanSunay/gohil/shell.Start", File: "/src/gohil/shell/shell.go", Line: 7},
+		Buffer:  &bytes.Buffer{},
+	}
+
+	got, err := (&gohilLogFormatter{}).Format(entry)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the caller is reported only when the logger has been asked to
+	if wanted := `INFO Evaluated backend=vm error="no way" steps=42` + "\n"; !strings.HasSuffix(string(got), wanted) {
+		t.Errorf("expected the suffix %q, but got %q", wanted, got)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected []string
+	}{
+		{FormatText, []string{"INFO Evaluated ", "caller=formatter_test.go:", "session=abc", "steps=42"}},
+		{FormatJSON, []string{`"level":"info"`, `"msg":"Evaluated"`, `"file":"formatter_test.go:`, `"session":"abc"`, `"steps":42`}},
+		{FormatLogfmt, []string{"level=info", "msg=Evaluated", "file=\"formatter_test.go:", "session=abc", "steps=42"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			caller := true
+			logger, err := newLogger(Config{Level: "info", Format: tt.format, Caller: &caller, SessionID: "abc"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			logger.SetOutput(&out)
+
+			logger.WithField("steps", 42).Info("Evaluated")
+			for _, expected := range tt.expected {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("expected %q in %q", expected, out.String())
+				}
+			}
+		})
+	}
+
+	if _, err := newLogger(Config{Level: "info", Format: "xml"}); err == nil {
+		t.Errorf("expected an error for an invalid format")
+	}
+}
@@ -2,14 +2,19 @@ package logger
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 const (
-	logFilePath              = "/Users/hhalil/Documents/Personal/system-programming/src/gohil/gohil.log"
 	defaultLogLevel          = logrus.InfoLevel
 	defaultLogFileMaxSizeMB  = 10
 	defaultLogFileMaxBackups = 5
@@ -19,33 +24,85 @@ const (
 // StdLog holds the global default logger.
 var StdLog = logrus.StandardLogger()
 
-// InitGlobalLogging initializes the default logger.
+// InitGlobalLogging initializes the default logger from the config file and the environment,
+// see LoadConfig. If they are invalid, the default config is used and the problem is logged.
 func InitGlobalLogging() {
-	StdLog = newLogger(logFilePath, defaultLogLevel, defaultLogFileMaxSizeMB, defaultLogFileMaxBackups)
+	config, err := LoadConfig("", Config{})
+	if err == nil {
+		err = Init(config)
+		if err == nil {
+			return
+		}
+	}
+
+	StdLog, _ = newLogger(DefaultConfig())
+	StdLog.Warnf("Using the default logging configuration: %v", err)
+}
+
+// Init initializes the default logger with the given config.
+func Init(config Config) error {
+	logger, err := newLogger(config)
+	if err != nil {
+		return err
+	}
+
+	StdLog = logger
+	return nil
 }
 
 // newLogger creates a new logger instance.
-func newLogger(logFilePath string, level logrus.Level, mb int, backups int) *logrus.Logger {
+func newLogger(config Config) (*logrus.Logger, error) {
 	logger := logrus.New()
 
+	level, err := logrus.ParseLevel(config.Level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level %q", config.Level)
+	}
+
+	sessionID := config.SessionID
+	if sessionID == "" {
+		sessionID = newSessionID()
+	}
+
+	formatter, err := newFormatter(config.Format, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
 	logger.SetLevel(level)
-	logger.SetFormatter(&gohilLogFormatter{})
+	logger.SetFormatter(formatter)
+	logger.SetReportCaller(config.ReportCaller())
 
-	if logFilePath == "" {
+	switch config.File {
+	case "", FileStderr:
 		logger.SetOutput(os.Stderr)
-	} else {
+	case FileDisabled:
+		logger.SetOutput(ioutil.Discard)
+		// nothing is written, so nothing has to be formatted either
+		logger.SetLevel(logrus.PanicLevel)
+	default:
 		// Setup rotating log file writer.
 		fileRotateLogWriter := lumberjack.Logger{
-			Filename:   logFilePath,
-			MaxSize:    mb,
-			MaxBackups: backups,
-			MaxAge:     0, // Rotate based on size only.
+			Filename:   config.File,
+			MaxSize:    config.MaxSizeMB,
+			MaxBackups: intValue(config.MaxBackups),
+			MaxAge:     intValue(config.MaxAgeDays),
 			Compress:   true}
 
 		logger.SetOutput(&fileRotateLogWriter)
 	}
 
-	return logger
+	return logger, nil
+}
+
+// newSessionID returns a random ID, which is unique for every run in practice
+func newSessionID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+
+	return hex.EncodeToString(id)
 }
 
 // GetFromContext returns a context log entry on the logger instance
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"context"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
@@ -19,19 +20,41 @@ func TestInitGlobalLogging(t *testing.T) {
 }
 
 func TestNewLoggerExpectStdErrLoggerForEmptyFilename(t *testing.T) {
-	stdErrLogger := newLogger("", defaultLogLevel, 5, 5)
+	maxBackups := 5
+	stdErrLogger, err := newLogger(Config{Level: "info", MaxSizeMB: 5, MaxBackups: &maxBackups})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if !reflect.DeepEqual(stdErrLogger.Out, os.Stderr) {
 		t.Errorf("expected the logger to be redirected to stderr")
 	}
 }
 
 func TestNewLoggerExpectLumberjackRotatingLogger(t *testing.T) {
-	lumberjackLogger := newLogger("dummyfilename", defaultLogLevel, 5, 5)
+	maxBackups := 5
+	lumberjackLogger, err := newLogger(Config{File: "dummyfilename", Level: "info", MaxSizeMB: 5, MaxBackups: &maxBackups})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if _, ok := lumberjackLogger.Out.(*lumberjack.Logger); !ok {
 		t.Errorf("expected the type assertion to be of lumberjack.Logger type")
 	}
 }
 
+func TestNewLoggerDisabled(t *testing.T) {
+	disabledLogger, err := newLogger(Config{File: FileDisabled, Level: "debug"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if disabledLogger.Out != ioutil.Discard || disabledLogger.IsLevelEnabled(logrus.ErrorLevel) {
+		t.Errorf("expected the logger to discard everything")
+	}
+
+	if _, err := newLogger(Config{Level: "loud"}); err == nil {
+		t.Errorf("expected an error for an invalid level")
+	}
+}
+
 func TestGetFromContextIfLoggerPresentInContext(t *testing.T) {
 	ctx := context.Background()
 	expectedLogger := &logrus.Logger{}
@@ -1,13 +1,20 @@
 package object
 
+import (
+	"fmt"
+	"sort"
+)
+
 type Environment struct {
 	store map[string]Object
-	outer *Environment // used for scope environment
+	// constants are the names in store that were bound by SetConst
+	constants map[string]bool
+	outer     *Environment // used for scope environment
 }
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, constants: make(map[string]bool), outer: nil}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
@@ -24,7 +31,61 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
-func (e *Environment) Set(name string, val Object) Object {
+// Set binds the value to the name in this environment.
+// A constant of this environment cannot be rebound, the error is returned instead.
+func (e *Environment) Set(name string, val Object) *Error {
+	if e.constants[name] {
+		return &Error{Message: fmt.Sprintf("cannot redeclare constant %s", name)}
+	}
+
 	e.store[name] = val
-	return val
+	return nil
+}
+
+// SetConst binds the value to the name in this environment as a constant,
+// which cannot be rebound or assigned, but can be shadowed by an enclosed environment
+func (e *Environment) SetConst(name string, val Object) *Error {
+	if err := e.Set(name, val); err != nil {
+		return err
+	}
+
+	e.constants[name] = true
+	return nil
+}
+
+// Assign updates the nearest binding of the name, in this or in an outer environment.
+// It is an error if the name is not bound or if it is a constant, Set binds it in this environment instead.
+func (e *Environment) Assign(name string, val Object) *Error {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; !ok {
+			continue
+		}
+
+		if env.constants[name] {
+			return &Error{Message: fmt.Sprintf("cannot assign to constant %s", name)}
+		}
+		env.store[name] = val
+		return nil
+	}
+
+	return &Error{Message: "identifier not found: " + name}
+}
+
+// Names returns the sorted names that are visible from the environment,
+// including the names that are bound in the outer environments
+func (e *Environment) Names() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			// shadowed names are listed once
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
 }
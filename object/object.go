@@ -3,9 +3,14 @@ package object
 import (
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 
+	"github.com/HakanSunay/gohil/code"
 	"github.com/HakanSunay/gohil/syntaxtree"
+	"github.com/HakanSunay/gohil/token"
 )
 
 type (
@@ -15,15 +20,19 @@ type (
 
 const (
 	IntegerObject     Type = "Integer"
+	FloatObject       Type = "Float"
 	BooleanObject     Type = "Boolean"
 	NullObject        Type = "Null"
 	ReturnValueObject Type = "ReturnValue"
+	BranchObject      Type = "Branch"
 	ErrorObject       Type = "Error"
 	FunctionObject    Type = "Function"
 	StringObject      Type = "String"
 	BuiltinObject     Type = "Builtin"
 	ArrayObject       Type = "Array"
 	HashObject        Type = "Hash"
+
+	CompiledFunctionObject Type = "CompiledFunction"
 )
 
 type Object interface {
@@ -55,6 +64,67 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// BigInteger is an integer that does not fit in Integer.
+// Integers are promoted to BigInteger on overflow and demoted back when the value fits again
+// (see IntegerFromBig), therefore gohil programs see both of them as Integer.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Type() Type {
+	return IntegerObject
+}
+
+func (bi *BigInteger) Inspect() string {
+	return bi.Value.String()
+}
+
+// HashKey is used when we are using BigInteger objects as keys for Hash Objects,
+// a BigInteger never has the value of an Integer, so their keys do not have to match
+func (bi *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(bi.Value.String()))
+
+	return HashKey{Type: bi.Type(), Value: h.Sum64()}
+}
+
+// IntegerFromBig returns the value as an Integer if it fits in one, as a BigInteger otherwise
+func IntegerFromBig(value *big.Int) Object {
+	if value.IsInt64() && int64(int(value.Int64())) == value.Int64() {
+		return &Integer{Value: int(value.Int64())}
+	}
+
+	return &BigInteger{Value: value}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() Type {
+	return FloatObject
+}
+
+// Inspect always shows that the value is a float, e.g. 3.0 instead of 3
+func (f *Float) Inspect() string {
+	str := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(str, ".eIN") {
+		str += ".0"
+	}
+	return str
+}
+
+// HashKey is used when we are using Float objects as keys for Hash Objects.
+// Floats with an integral value are equal to the corresponding integers,
+// therefore they share the hash key of the integer.
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return (&Integer{Value: int(f.Value)}).HashKey()
+	}
+
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -105,8 +175,39 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Branch is the result of a break or a continue statement, like ReturnValue it skips
+// the rest of the statements, up to the enclosing loop, which either ends or continues.
+type Branch struct {
+	Break bool
+}
+
+func (b *Branch) Type() Type {
+	return BranchObject
+}
+
+func (b *Branch) Inspect() string {
+	if b.Break {
+		return "break"
+	}
+	return "continue"
+}
+
+// MainFunctionName is used in tracebacks for code that is not inside of a function
+const MainFunctionName = "<main>"
+
+// Error is a runtime error. Besides the message it records the position of the node
+// that failed and the function calls that were active at that moment,
+// starting from the outermost one.
 type Error struct {
 	Message string
+	Pos     token.Position
+	Stack   []Frame
+}
+
+// Frame describes a single function call in the call stack of an Error
+type Frame struct {
+	Function string         // name of the called function
+	Pos      token.Position // position of the call expression
 }
 
 func (e *Error) Type() Type {
@@ -117,6 +218,77 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// Error implements the error interface, so that runtime errors can be returned to Go code
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// maxRepeatedTraceLines is the number of identical trace lines that are printed,
+// before the rest of them (e.g. of an infinite recursion) are summarized in a single line
+const maxRepeatedTraceLines = 3
+
+// Traceback renders the error together with the call stack, starting from the innermost call.
+// The source is used as a prefix for the positions, e.g. the script file name, it can be empty.
+// E.g:
+//
+//	ERROR: identifier not found: x
+//	    at inner (script.ghl:3:5)
+//	    at outer (script.ghl:6:3)
+//	    at <main> (script.ghl:8:1)
+func (e *Error) Traceback(source string) string {
+	var builder strings.Builder
+
+	builder.WriteString(e.Inspect())
+
+	if !e.Pos.IsValid() {
+		return builder.String()
+	}
+
+	// the position of the error is inside the innermost function,
+	// every call position is inside the function one level above it
+	var lines []string
+	pos := e.Pos
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		lines = append(lines, traceLine(e.Stack[i].Function, source, pos))
+		pos = e.Stack[i].Pos
+	}
+	lines = append(lines, traceLine(MainFunctionName, source, pos))
+
+	for i := 0; i < len(lines); {
+		repeated := 1
+		for i+repeated < len(lines) && lines[i+repeated] == lines[i] {
+			repeated++
+		}
+
+		for j := 0; j < repeated && j < maxRepeatedTraceLines; j++ {
+			builder.WriteString(lines[i])
+		}
+		if repeated > maxRepeatedTraceLines {
+			fmt.Fprintf(&builder, "\n    ... previous line repeated %d more times", repeated-maxRepeatedTraceLines)
+		}
+
+		i += repeated
+	}
+
+	return builder.String()
+}
+
+func traceLine(function string, source string, pos token.Position) string {
+	var builder strings.Builder
+
+	builder.WriteString("\n    at ")
+	builder.WriteString(function)
+	builder.WriteString(" (")
+	if source != "" {
+		builder.WriteString(source)
+		builder.WriteString(":")
+	}
+	builder.WriteString(pos.String())
+	builder.WriteString(")")
+
+	return builder.String()
+}
+
 type Function struct {
 	Parameters []*syntaxtree.Identifier
 	Body       *syntaxtree.BlockStmt
@@ -128,10 +300,14 @@ func (f *Function) Type() Type {
 }
 
 func (f *Function) Inspect() string {
+	return inspectFunction(f.Parameters, f.Body)
+}
+
+func inspectFunction(parameters []*syntaxtree.Identifier, body *syntaxtree.BlockStmt) string {
 	var builder strings.Builder
 
 	var params []string
-	for _, p := range f.Parameters {
+	for _, p := range parameters {
 		params = append(params, p.String())
 	}
 
@@ -139,12 +315,55 @@ func (f *Function) Inspect() string {
 	builder.WriteString("(")
 	builder.WriteString(strings.Join(params, ", "))
 	builder.WriteString(") {\n")
-	builder.WriteString(f.Body.String())
+	builder.WriteString(body.String())
 	builder.WriteString("\n}")
 
 	return builder.String()
 }
 
+// CompiledFunction is a function literal compiled to bytecode.
+// It holds on to the constant pool of the program it was compiled in,
+// so that it can be called even after that program has finished.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	SourceMap     code.SourceMap
+	Constants     []Object
+	NumLocals     int
+	NumParameters int
+	// LocalNames are the names of the local slots, for the errors about the locals that are not bound yet
+	LocalNames []string
+
+	// Name is the name the function was bound to with let, if any
+	Name string
+
+	// Parameters and Body are kept for Inspect
+	Parameters []*syntaxtree.Identifier
+	Body       *syntaxtree.BlockStmt
+}
+
+func (cf *CompiledFunction) Type() Type {
+	return CompiledFunctionObject
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure is the runtime representation of a compiled function together with its free variables.
+// To gohil programs it is just a Function.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() Type {
+	return FunctionObject
+}
+
+func (c *Closure) Inspect() string {
+	return inspectFunction(c.Fn.Parameters, c.Fn.Body)
+}
+
 type String struct {
 	Value string
 }
@@ -182,6 +401,8 @@ func (b *Builtin) Inspect() string {
 
 type Array struct {
 	Elements []Object
+	// Frozen arrays cannot be modified, neither by indexed assignments nor by builtins, see Freeze
+	Frozen bool
 }
 
 func (ao *Array) Type() Type {
@@ -189,18 +410,7 @@ func (ao *Array) Type() Type {
 }
 
 func (ao *Array) Inspect() string {
-	var builder strings.Builder
-
-	var elements []string
-	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
-	}
-
-	builder.WriteString("[")
-	builder.WriteString(strings.Join(elements, ", "))
-	builder.WriteString("]")
-
-	return builder.String()
+	return inspect(ao, make(map[Object]bool))
 }
 
 type HashKey struct {
@@ -214,21 +424,82 @@ type HashPair struct {
 }
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	// Frozen hashes cannot be modified, neither by indexed assignments nor by builtins, see Freeze
+	Frozen bool
 }
 
 func (h *Hash) Type() Type { return HashObject }
 
 func (h *Hash) Inspect() string {
+	return inspect(h, make(map[Object]bool))
+}
+
+// inspect returns the representation of the object, visiting holds the arrays and hashes
+// that contain it. An array or a hash that contains itself is represented as [...] or {...} inside of itself.
+func inspect(obj Object, visiting map[Object]bool) string {
 	var builder strings.Builder
 
-	var pairs []string
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	switch obj := obj.(type) {
+	case *Array:
+		if visiting[obj] {
+			return "[...]"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		var elements []string
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, visiting))
+		}
+
+		builder.WriteString("[")
+		builder.WriteString(strings.Join(elements, ", "))
+		builder.WriteString("]")
+	case *Hash:
+		if visiting[obj] {
+			return "{...}"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		var pairs []string
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), inspect(pair.Value, visiting)))
+		}
+
+		builder.WriteString("{")
+		builder.WriteString(strings.Join(pairs, ", "))
+		builder.WriteString("}")
+	default:
+		return obj.Inspect()
 	}
 
-	builder.WriteString("{")
-	builder.WriteString(strings.Join(pairs, ", "))
-	builder.WriteString("}")
-
 	return builder.String()
 }
+
+// Freeze makes the arrays and hashes in the value immutable, including the ones they contain,
+// the other values cannot be modified anyway. It returns the value.
+func Freeze(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		// an array that is already frozen may contain itself
+		if obj.Frozen {
+			break
+		}
+		obj.Frozen = true
+		for _, element := range obj.Elements {
+			Freeze(element)
+		}
+	case *Hash:
+		if obj.Frozen {
+			break
+		}
+		obj.Frozen = true
+		// the keys are hashable, which are immutable values
+		for _, pair := range obj.Pairs {
+			Freeze(pair.Value)
+		}
+	}
+
+	return obj
+}
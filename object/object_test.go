@@ -1,6 +1,12 @@
 package object
 
-import "testing"
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/HakanSunay/gohil/token"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -22,3 +28,114 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestTracebackCollapsesRepeatedFrames(t *testing.T) {
+	call := token.Position{Line: 1, Column: 16}
+	err := &Error{
+		Message: "stack depth exceeded",
+		Pos:     call,
+		Stack: []Frame{
+			{Function: "f", Pos: token.Position{Line: 1, Column: 23}},
+			{Function: "f", Pos: call},
+			{Function: "f", Pos: call},
+			{Function: "f", Pos: call},
+			{Function: "f", Pos: call},
+			{Function: "f", Pos: call},
+		},
+	}
+
+	expected := `ERROR: stack depth exceeded
+    at f (1:16)
+    at f (1:16)
+    at f (1:16)
+    ... previous line repeated 3 more times
+    at <main> (1:23)`
+	if traceback := err.Traceback(""); traceback != expected {
+		t.Errorf("expected traceback:\n%s\nbut got:\n%s", expected, traceback)
+	}
+}
+
+func TestIntegerFromBig(t *testing.T) {
+	if small, ok := IntegerFromBig(big.NewInt(-42)).(*Integer); !ok || small.Value != -42 {
+		t.Errorf("expected Integer -42, but got %v", small)
+	}
+
+	value, _ := new(big.Int).SetString("-9223372036854775809", 10)
+	large, ok := IntegerFromBig(value).(*BigInteger)
+	if !ok {
+		t.Fatalf("expected a BigInteger")
+	}
+	if large.Type() != IntegerObject || large.Inspect() != "-9223372036854775809" {
+		t.Errorf("unexpected BigInteger %s %s", large.Type(), large.Inspect())
+	}
+
+	same := &BigInteger{Value: new(big.Int).Set(value)}
+	if large.HashKey() != same.HashKey() {
+		t.Errorf("equal big integers must have the same hash key")
+	}
+}
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("b", &Integer{Value: 1})
+	outer.Set("a", &Integer{Value: 2})
+
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("c", &Integer{Value: 3})
+	inner.Set("a", &Integer{Value: 4})
+
+	if names := inner.Names(); !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("expected [a b c], but got %v", names)
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("a", &Integer{Value: 1})
+
+	inner := NewEnclosedEnvironment(outer)
+	if err := inner.Assign("a", &Integer{Value: 2}); err != nil {
+		t.Fatalf("expected a to be assigned, but got %s", err.Message)
+	}
+	if err := inner.Assign("b", &Integer{Value: 3}); err == nil || err.Message != "identifier not found: b" {
+		t.Errorf("expected the undefined b not to be assigned, but got %v", err)
+	}
+
+	if value, _ := outer.Get("a"); value.Inspect() != "2" {
+		t.Errorf("expected the outer a to be 2, but got %s", value.Inspect())
+	}
+	if _, ok := inner.Get("b"); ok {
+		t.Errorf("expected b to stay undefined")
+	}
+}
+
+func TestEnvironmentConstants(t *testing.T) {
+	outer := NewEnvironment()
+	if err := outer.SetConst("c", &Integer{Value: 1}); err != nil {
+		t.Fatalf("unexpected error %s", err.Message)
+	}
+
+	inner := NewEnclosedEnvironment(outer)
+	tests := []struct {
+		name     string
+		err      *Error
+		expected string
+	}{
+		{"set", outer.Set("c", &Integer{Value: 2}), "cannot redeclare constant c"},
+		{"set const", outer.SetConst("c", &Integer{Value: 2}), "cannot redeclare constant c"},
+		{"assign", inner.Assign("c", &Integer{Value: 2}), "cannot assign to constant c"},
+	}
+	for _, tt := range tests {
+		if tt.err == nil || tt.err.Message != tt.expected {
+			t.Errorf("%s: expected %q, but got %v", tt.name, tt.expected, tt.err)
+		}
+	}
+
+	// an enclosed environment can shadow the constant
+	if err := inner.Set("c", &Integer{Value: 3}); err != nil {
+		t.Errorf("unexpected error %s", err.Message)
+	}
+	if value, _ := outer.Get("c"); value.Inspect() != "1" {
+		t.Errorf("expected the constant to stay 1, but got %s", value.Inspect())
+	}
+}
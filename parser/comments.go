@@ -0,0 +1,32 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/HakanSunay/gohil/token"
+)
+
+// docComment returns the text of the doc comment of the statement at pos,
+// which are the comments that end on the line before it (or on its line) without empty lines between them.
+// The delimiters of the comments and the indentation of block comments are removed.
+func docComment(comments []token.Token, pos token.Position) string {
+	start := len(comments)
+	for line := pos.Line; start > 0 && comments[start-1].End.Line >= line-1; start-- {
+		line = comments[start-1].Pos.Line
+	}
+
+	var lines []string
+	for _, comment := range comments[start:] {
+		if strings.HasPrefix(comment.Literal, "//") {
+			lines = append(lines, strings.TrimPrefix(comment.Literal[2:], " "))
+			continue
+		}
+
+		text := strings.TrimSuffix(strings.TrimPrefix(comment.Literal, "/*"), "*/")
+		for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
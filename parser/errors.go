@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/HakanSunay/gohil/token"
+)
+
+// ParseError describes a syntax error encountered while parsing.
+// Expected is only set when the parser was looking for a specific token type,
+// Actual holds the type of the token that was found instead.
+type ParseError struct {
+	Pos      token.Position
+	Expected token.Type
+	Actual   token.Type
+	Message  string
+}
+
+// Error renders the error in the <line>:<column>: <message> format
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// ErrorList is a list of parse errors that implements the error interface
+type ErrorList []*ParseError
+
+// Error renders all of the errors, one per line
+func (el ErrorList) Error() string {
+	messages := make([]string, 0, len(el))
+	for _, err := range el {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// addError registers a new parse error, unless the parser is already recovering from one.
+// While recovering, the errors are most likely a consequence of the first one,
+// therefore they are not reported to the user.
+func (p *Parser) addError(err *ParseError) {
+	if p.recovering {
+		return
+	}
+
+	p.recovering = true
+	p.errors = append(p.errors, err)
+}
+
+// nextTokenError reports that the next token is not of the expected type
+func (p *Parser) nextTokenError(expected token.Type) {
+	p.addError(&ParseError{
+		Pos:      p.nextToken.Pos,
+		Expected: expected,
+		Actual:   p.nextToken.Type,
+		Message: fmt.Sprintf("expected (%s) after (%s), but got (%s)",
+			expected, p.currentToken.Type, describe(p.nextToken)),
+	})
+}
+
+// currentTokenError reports that the current token can not be used at its position
+func (p *Parser) currentTokenError(format string, args ...interface{}) {
+	p.addError(&ParseError{
+		Pos:     p.currentToken.Pos,
+		Actual:  p.currentToken.Type,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// lexerError reports the error that made the lexer return the illegal token, e.g. an unterminated string,
+// it returns false if there is no such error
+func (p *Parser) lexerError(tok token.Token) bool {
+	if tok.Type != token.Illegal {
+		return false
+	}
+
+	for _, err := range p.lxr.Errors() {
+		if tok.Pos.Offset <= err.Pos.Offset && err.Pos.Offset < tok.End.Offset {
+			p.addError(&ParseError{Pos: err.Pos, Actual: token.Illegal, Message: err.Message})
+			return true
+		}
+	}
+
+	return false
+}
+
+// describe returns a human readable description of the token for error messages
+func describe(tok token.Token) string {
+	switch tok.Type {
+	case token.EOF:
+		return "end of input"
+	case token.Illegal, token.Identifier, token.Int, token.Float, token.String:
+		return fmt.Sprintf("%s %q", tok.Type, tok.Literal)
+	default:
+		return tok.Type.String()
+	}
+}
+
+// synchronize skips the tokens of a malformed statement,
+// so that a single mistake results in a single error and parsing can continue.
+// It stops on the ';' that ends the statement or right before the '}' that closes the enclosing block.
+// The returned value reports whether the current token is the '}' that closes the enclosing block,
+// which happens when the error was reported on that very token.
+func (p *Parser) synchronize() bool {
+	p.recovering = false
+	errorPos := p.errors[len(p.errors)-1].Pos
+
+	depth := 0
+	for {
+		switch p.currentToken.Type {
+		case token.EOF:
+			return false
+		case token.SemiColon:
+			if depth == 0 {
+				return false
+			}
+		case token.LeftBrace, token.LeftParenthesis, token.LeftBracket:
+			depth++
+		case token.RightBrace:
+			if depth == 0 && p.currentToken.Pos == errorPos {
+				return true
+			}
+			if depth > 0 {
+				depth--
+			}
+		case token.RightParenthesis, token.RightBracket:
+			if depth > 0 {
+				depth--
+			}
+		}
+
+		if depth == 0 && (p.nextToken.Type == token.RightBrace || p.nextToken.Type == token.EOF) {
+			return false
+		}
+
+		p.jump()
+	}
+}
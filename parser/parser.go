@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
 
 	"github.com/HakanSunay/gohil/lexer"
@@ -11,6 +12,9 @@ import (
 
 const (
 	Lowest = iota + 1
+	Assignment
+	LogicalOr
+	LogicalAnd
 	Equals
 	LessGreater
 	Sum
@@ -21,17 +25,29 @@ const (
 )
 
 var precedences = map[token.Type]int{
+	token.Assign:         Assignment,
+	token.PlusAssign:     Assignment,
+	token.MinusAssign:    Assignment,
+	token.AsteriskAssign: Assignment,
+	token.SlashAssign:    Assignment,
+
+	token.Or:  LogicalOr,
+	token.And: LogicalAnd,
+
 	token.Equal:    Equals,
 	token.NotEqual: Equals,
 
-	token.LessThan:    LessGreater,
-	token.GreaterThan: LessGreater,
+	token.LessThan:     LessGreater,
+	token.GreaterThan:  LessGreater,
+	token.LessEqual:    LessGreater,
+	token.GreaterEqual: LessGreater,
 
 	token.Plus:  Sum,
 	token.Minus: Sum,
 
 	token.Slash:    Product,
 	token.Asterisk: Product,
+	token.Percent:  Product,
 
 	token.Function:        Call,
 	token.LeftParenthesis: Call,
@@ -61,10 +77,23 @@ type Parser struct {
 	currentToken token.Token
 	nextToken    token.Token
 
+	// the comments that are on their own lines right before the current and the next token,
+	// a comment on the line of the token before them belongs to that token
+	currentComments []token.Token
+	nextComments    []token.Token
+
 	prefixMap map[token.Type]prefixParseFN
 	infixMap  map[token.Type]infixParseFN
 
-	errors []string
+	errors []*ParseError
+
+	// recovering is set after an error has been reported,
+	// until the parser skips the rest of the malformed statement
+	recovering bool
+
+	// loopDepth is the number of loops around the current token within the current function,
+	// break and continue are only allowed inside of them
+	loopDepth int
 }
 
 // NewParser is the constructor for the Parser type
@@ -75,7 +104,7 @@ func NewParser(lxr *lexer.Lexer) *Parser {
 		prefixMap: make(map[token.Type]prefixParseFN),
 		infixMap:  make(map[token.Type]infixParseFN),
 
-		errors: []string{},
+		errors: []*ParseError{},
 	}
 
 	parser.jump()
@@ -86,6 +115,7 @@ func NewParser(lxr *lexer.Lexer) *Parser {
 	// prefix funcs
 	parser.addPrefixFunc(token.Identifier, parser.parseIdentifier)
 	parser.addPrefixFunc(token.Int, parser.parseIntegerLiteral)
+	parser.addPrefixFunc(token.Float, parser.parseFloatLiteral)
 	parser.addPrefixFunc(token.True, parser.parseBooleanLiteral)
 	parser.addPrefixFunc(token.False, parser.parseBooleanLiteral)
 	parser.addPrefixFunc(token.ExclamationMark, parser.parsePrefixExpression)
@@ -106,6 +136,16 @@ func NewParser(lxr *lexer.Lexer) *Parser {
 	parser.addInfixFunc(token.NotEqual, parser.parseInfixExpression)
 	parser.addInfixFunc(token.LessThan, parser.parseInfixExpression)
 	parser.addInfixFunc(token.GreaterThan, parser.parseInfixExpression)
+	parser.addInfixFunc(token.LessEqual, parser.parseInfixExpression)
+	parser.addInfixFunc(token.GreaterEqual, parser.parseInfixExpression)
+	parser.addInfixFunc(token.Percent, parser.parseInfixExpression)
+	parser.addInfixFunc(token.And, parser.parseInfixExpression)
+	parser.addInfixFunc(token.Or, parser.parseInfixExpression)
+	parser.addInfixFunc(token.Assign, parser.parseAssignExpression)
+	parser.addInfixFunc(token.PlusAssign, parser.parseAssignExpression)
+	parser.addInfixFunc(token.MinusAssign, parser.parseAssignExpression)
+	parser.addInfixFunc(token.AsteriskAssign, parser.parseAssignExpression)
+	parser.addInfixFunc(token.SlashAssign, parser.parseAssignExpression)
 	parser.addInfixFunc(token.LeftParenthesis, parser.parseCallExpression)
 	parser.addInfixFunc(token.LeftBracket, parser.parseIndexExpressions)
 
@@ -114,8 +154,20 @@ func NewParser(lxr *lexer.Lexer) *Parser {
 
 // jump moves the current and next token to the corresponding next token in the lexer
 func (p *Parser) jump() {
-	p.currentToken = p.nextToken
+	p.currentToken, p.currentComments = p.nextToken, p.nextComments
+
+	// a lexer can return the comments as tokens or skip them, either way they are kept aside
+	p.nextComments = nil
 	p.nextToken = p.lxr.NextToken()
+	for p.nextToken.Type == token.Comment {
+		p.nextComments = append(p.nextComments, p.nextToken)
+		p.nextToken = p.lxr.NextToken()
+	}
+	p.nextComments = append(p.nextComments, p.lxr.Comments()...)
+
+	for len(p.nextComments) > 0 && p.nextComments[0].Pos.Line == p.currentToken.End.Line {
+		p.nextComments = p.nextComments[1:]
+	}
 }
 
 // ParseProgram performs recursive descent parsing (aka Pratt parsing)
@@ -127,8 +179,11 @@ func (p *Parser) ParseProgram() *syntaxtree.Program {
 		// for each token double (cur, nxt) call parseStatement
 		statement := p.parseStatement()
 
-		// if the resulting statement is not nil, add it to the program
-		if statement != nil {
+		if p.recovering {
+			// the statement is malformed, skip the rest of it and carry on
+			p.synchronize()
+		} else if statement != nil {
+			// if the resulting statement is not nil, add it to the program
 			program.Statements = append(program.Statements, statement)
 		}
 
@@ -142,23 +197,31 @@ func (p *Parser) ParseProgram() *syntaxtree.Program {
 // parseStatement handles parsing statements
 func (p *Parser) parseStatement() syntaxtree.Stmt {
 	switch p.currentToken.Type {
-	case token.Let:
+	case token.Let, token.Const:
 		return p.parseLetStatement()
 	case token.Return:
 		return p.parseReturnStatement()
+	case token.While:
+		return p.parseWhileStatement()
+	case token.For:
+		return p.parseForStatement()
+	case token.Break, token.Continue:
+		return p.parseBranchStatement()
+	case token.SemiColon:
+		// empty statement, nothing to parse
+		return nil
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-// parseLetStatement takes care of parsing let statements
+// parseLetStatement takes care of parsing let and const statements
 func (p *Parser) parseLetStatement() *syntaxtree.LetStmt {
-	stmt := &syntaxtree.LetStmt{Token: p.currentToken}
+	stmt := &syntaxtree.LetStmt{Token: p.currentToken, Doc: docComment(p.currentComments, p.currentToken.Pos)}
 
 	// if the next token is not an identifier, this is an invalid let statement
 	if p.nextToken.Type != token.Identifier {
-		msg := generateErrorMsg(p.currentToken.Type, token.Identifier, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.Identifier)
 		return nil
 	}
 
@@ -173,8 +236,7 @@ func (p *Parser) parseLetStatement() *syntaxtree.LetStmt {
 	// currently, we have let identifier
 	// if the next token is not an equal assign, this is an invalid let statement
 	if p.nextToken.Type != token.Assign {
-		msg := generateErrorMsg(p.currentToken.Type, token.Assign, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.Assign)
 		return nil
 	}
 	// jump to to the assign token
@@ -184,22 +246,23 @@ func (p *Parser) parseLetStatement() *syntaxtree.LetStmt {
 	p.jump()
 	stmt.Value = p.parseExpression(Lowest)
 
-	if p.nextToken.Type == token.SemiColon {
-		p.jump()
-	}
+	p.skipSemiColon()
 
 	return stmt
 }
 
-// TODO: logging
-// generateErrorMsg generated error message for unexpected token retrieval
-func generateErrorMsg(cur token.Type, exp token.Type, actual token.Type) string {
-	return fmt.Sprintf("Current token of type (%s) expected next token of type (%s), but got (%s)",
-		cur, exp, actual)
+// GetErrors returns the encountered errors of the parser rendered as strings
+func (p *Parser) GetErrors() []string {
+	messages := make([]string, 0, len(p.errors))
+	for _, err := range p.errors {
+		messages = append(messages, err.Error())
+	}
+
+	return messages
 }
 
-// GetErrors returns the encountered errors of the parser
-func (p *Parser) GetErrors() []string {
+// Errors returns the encountered errors of the parser
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
@@ -212,13 +275,120 @@ func (p *Parser) parseReturnStatement() *syntaxtree.ReturnStmt {
 	// parse the return value
 	stmt.ReturnValue = p.parseExpression(Lowest)
 
-	if p.nextToken.Type == token.SemiColon {
-		p.jump()
+	p.skipSemiColon()
+
+	return stmt
+}
+
+// parseWhileStatement parses while (condition) { body }
+func (p *Parser) parseWhileStatement() *syntaxtree.WhileStmt {
+	stmt := &syntaxtree.WhileStmt{Token: p.currentToken}
+
+	if p.nextToken.Type != token.LeftParenthesis {
+		p.nextTokenError(token.LeftParenthesis)
+		return nil
+	}
+	// jump to the left parenthesis
+	p.jump()
+
+	// jump to the condition
+	p.jump()
+	stmt.Condition = p.parseExpression(Lowest)
+
+	if p.nextToken.Type != token.RightParenthesis {
+		p.nextTokenError(token.RightParenthesis)
+		return nil
 	}
+	p.jump()
+
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	p.skipSemiColon()
 
 	return stmt
 }
 
+// parseForStatement parses for (variable in iterable) { body }
+func (p *Parser) parseForStatement() *syntaxtree.ForStmt {
+	stmt := &syntaxtree.ForStmt{Token: p.currentToken}
+
+	if p.nextToken.Type != token.LeftParenthesis {
+		p.nextTokenError(token.LeftParenthesis)
+		return nil
+	}
+	p.jump()
+
+	if p.nextToken.Type != token.Identifier {
+		p.nextTokenError(token.Identifier)
+		return nil
+	}
+	p.jump()
+	stmt.Variable = &syntaxtree.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	if p.nextToken.Type != token.In {
+		p.nextTokenError(token.In)
+		return nil
+	}
+	p.jump()
+
+	// jump to the iterable
+	p.jump()
+	stmt.Iterable = p.parseExpression(Lowest)
+
+	if p.nextToken.Type != token.RightParenthesis {
+		p.nextTokenError(token.RightParenthesis)
+		return nil
+	}
+	p.jump()
+
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	p.skipSemiColon()
+
+	return stmt
+}
+
+// parseLoopBody parses the block after the next token, break and continue are allowed in it
+func (p *Parser) parseLoopBody() *syntaxtree.BlockStmt {
+	if p.nextToken.Type != token.LeftBrace {
+		p.nextTokenError(token.LeftBrace)
+		return nil
+	}
+	p.jump()
+
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.parseBlockStatement()
+}
+
+// parseBranchStatement parses break and continue, which must be inside of a loop
+func (p *Parser) parseBranchStatement() *syntaxtree.BranchStmt {
+	if p.loopDepth == 0 {
+		p.currentTokenError("%s is not in a loop", p.currentToken.Literal)
+		return nil
+	}
+
+	stmt := &syntaxtree.BranchStmt{Token: p.currentToken}
+	p.skipSemiColon()
+
+	return stmt
+}
+
+// skipSemiColon moves to the optional semicolon that terminates a statement.
+// After an error the semicolon is left for synchronize to find.
+func (p *Parser) skipSemiColon() {
+	if p.nextToken.Type == token.SemiColon && !p.recovering {
+		p.jump()
+	}
+}
+
 func (p *Parser) addPrefixFunc(tokenType token.Type, fn prefixParseFN) {
 	p.prefixMap[tokenType] = fn
 }
@@ -235,9 +405,7 @@ func (p *Parser) parseExpressionStatement() *syntaxtree.ExpressionStmt {
 	// 6;
 	// 6
 	// are both valid in gohil
-	if p.nextToken.Type == token.SemiColon {
-		p.jump()
-	}
+	p.skipSemiColon()
 
 	return stmt
 }
@@ -246,9 +414,9 @@ func (p *Parser) parseExpression(precedence int) syntaxtree.Expr {
 	// is there a parsing function that can handle the current token type
 	prefix, ok := p.prefixMap[p.currentToken.Type]
 	if !ok {
-		msg := fmt.Sprintf("no prefix parse function for (%s) found",
-			p.currentToken.Type)
-		p.errors = append(p.errors, msg)
+		if !p.lexerError(p.currentToken) {
+			p.currentTokenError("expected an expression, but got (%s)", describe(p.currentToken))
+		}
 		return nil
 	}
 	leftExpr := prefix()
@@ -256,9 +424,11 @@ func (p *Parser) parseExpression(precedence int) syntaxtree.Expr {
 	for p.nextToken.Type != token.SemiColon && precedence < p.getNextPrecedence() {
 		infix, ok := p.infixMap[p.nextToken.Type]
 		if !ok {
-			msg := fmt.Sprintf("no infix parse function for (%s) found",
-				p.nextToken.Type)
-			p.errors = append(p.errors, msg)
+			p.addError(&ParseError{
+				Pos:     p.nextToken.Pos,
+				Actual:  p.nextToken.Type,
+				Message: fmt.Sprintf("no infix parse function for (%s) found", p.nextToken.Type),
+			})
 			return leftExpr
 		}
 
@@ -278,16 +448,34 @@ func (p *Parser) parseIdentifier() syntaxtree.Expr {
 func (p *Parser) parseIntegerLiteral() syntaxtree.Expr {
 	integerLiteral := &syntaxtree.IntegerLiteral{Token: p.currentToken}
 	value, err := strconv.Atoi(p.currentToken.Literal)
-	if err != nil {
-		msg := fmt.Sprintf("could not parse (%s) to integer", p.currentToken.Literal)
-		p.errors = append(p.errors, msg)
+	if err == nil {
+		integerLiteral.Value = value
+		return integerLiteral
+	}
+
+	// literals that are too large for an int are arbitrary-precision integers
+	bigValue, ok := new(big.Int).SetString(p.currentToken.Literal, 10)
+	if !ok {
+		p.currentTokenError("could not parse (%s) to integer", p.currentToken.Literal)
 		return nil
 	}
 
-	integerLiteral.Value = value
+	integerLiteral.Big = bigValue
 	return integerLiteral
 }
 
+func (p *Parser) parseFloatLiteral() syntaxtree.Expr {
+	floatLiteral := &syntaxtree.FloatLiteral{Token: p.currentToken}
+	value, err := strconv.ParseFloat(p.currentToken.Literal, 64)
+	if err != nil {
+		p.currentTokenError("could not parse (%s) to float", p.currentToken.Literal)
+		return nil
+	}
+
+	floatLiteral.Value = value
+	return floatLiteral
+}
+
 func (p *Parser) parseBooleanLiteral() syntaxtree.Expr {
 	return &syntaxtree.BooleanLiteral{
 		Token: p.currentToken,
@@ -347,14 +535,44 @@ func (p *Parser) parseInfixExpression(leftExpr syntaxtree.Expr) syntaxtree.Expr
 	return expr
 }
 
+// parseAssignExpression parses an assignment to an identifier or an index expression,
+// assignments are right associative: x = y = 1 assigns 1 to y and then to x
+func (p *Parser) parseAssignExpression(target syntaxtree.Expr) syntaxtree.Expr {
+	expr := &syntaxtree.AssignExpr{
+		Token:    p.currentToken,
+		Target:   target,
+		Operator: p.currentToken.Literal,
+	}
+
+	// a malformed target has already been reported and may be missing some of its parts
+	if target == nil || p.recovering {
+		return nil
+	}
+
+	switch target.(type) {
+	case *syntaxtree.Identifier, *syntaxtree.IndexExpression:
+	default:
+		p.addError(&ParseError{
+			Pos:     target.Pos(),
+			Actual:  p.currentToken.Type,
+			Message: fmt.Sprintf("cannot assign to %s", target.String()),
+		})
+		return nil
+	}
+
+	p.jump()
+	expr.Value = p.parseExpression(Assignment - 1)
+
+	return expr
+}
+
 func (p *Parser) parseGroupedExpression() syntaxtree.Expr {
 	p.jump()
 
 	expr := p.parseExpression(Lowest)
 
 	if p.nextToken.Type != token.RightParenthesis {
-		msg := generateErrorMsg(p.currentToken.Type, token.RightParenthesis, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.RightParenthesis)
 		return nil
 	}
 
@@ -366,8 +584,7 @@ func (p *Parser) parseIfExpression() syntaxtree.Expr {
 	ifExpr := &syntaxtree.IfExpr{Token: p.currentToken}
 
 	if p.nextToken.Type != token.LeftParenthesis {
-		msg := generateErrorMsg(p.currentToken.Type, token.LeftParenthesis, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.LeftParenthesis)
 		return nil
 	}
 	// jump to the left parenthesis
@@ -379,16 +596,14 @@ func (p *Parser) parseIfExpression() syntaxtree.Expr {
 	ifExpr.Condition = p.parseExpression(Lowest)
 
 	if p.nextToken.Type != token.RightParenthesis {
-		msg := generateErrorMsg(p.currentToken.Type, token.RightParenthesis, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.RightParenthesis)
 		return nil
 	}
 	p.jump()
 
 	// parse the block statement leading (
 	if p.nextToken.Type != token.LeftBrace {
-		msg := generateErrorMsg(p.currentToken.Type, token.LeftBrace, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.LeftBrace)
 		return nil
 	}
 	p.jump()
@@ -401,8 +616,7 @@ func (p *Parser) parseIfExpression() syntaxtree.Expr {
 		p.jump()
 
 		if p.nextToken.Type != token.LeftBrace {
-			msg := generateErrorMsg(p.currentToken.Type, token.LeftBrace, p.nextToken.Type)
-			p.errors = append(p.errors, msg)
+			p.nextTokenError(token.LeftBrace)
 			return nil
 		}
 		p.jump()
@@ -419,15 +633,39 @@ func (p *Parser) parseBlockStatement() *syntaxtree.BlockStmt {
 		Statements: []syntaxtree.Stmt{},
 	}
 
+	// the statements of the block recover from their own errors, while an error of the statement
+	// that contains the block must still be recovered from after it
+	outer := p.recovering
+	defer func() { p.recovering = p.recovering || outer }()
+
 	p.jump()
-	for p.currentToken.Type != token.RightBrace {
+	for p.currentToken.Type != token.RightBrace && p.currentToken.Type != token.EOF {
 		stmt := p.parseStatement()
-		if stmt != nil {
+
+		if p.recovering {
+			// the malformed statement ended right at the closing brace of the block
+			if p.synchronize() {
+				continue
+			}
+		} else if stmt != nil {
 			blockStmt.Statements = append(blockStmt.Statements, stmt)
 		}
+
 		p.jump()
 	}
 
+	// the input ended before the block was closed
+	if p.currentToken.Type != token.RightBrace {
+		p.addError(&ParseError{
+			Pos:      p.currentToken.Pos,
+			Expected: token.RightBrace,
+			Actual:   p.currentToken.Type,
+			Message:  fmt.Sprintf("expected (%s) to close the block, but got (%s)", token.RightBrace, describe(p.currentToken)),
+		})
+		return blockStmt
+	}
+	blockStmt.Closing = p.currentToken
+
 	return blockStmt
 }
 
@@ -435,8 +673,7 @@ func (p *Parser) parseFunctionLiteral() syntaxtree.Expr {
 	fnLiteral := &syntaxtree.FunctionLiteral{Token: p.currentToken}
 
 	if p.nextToken.Type != token.LeftParenthesis {
-		msg := generateErrorMsg(p.currentToken.Type, token.LeftParenthesis, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.LeftParenthesis)
 		return nil
 	}
 	// jump to the left parenthesis
@@ -447,14 +684,17 @@ func (p *Parser) parseFunctionLiteral() syntaxtree.Expr {
 	// after parsing the parameters, the next token must be the left brace.
 	// opening the function body
 	if p.nextToken.Type != token.LeftBrace {
-		msg := generateErrorMsg(p.currentToken.Type, token.LeftBrace, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.LeftBrace)
 		return nil
 	}
 	p.jump()
 
-	// same as if expr consequence / alternative parsing
+	// same as if expr consequence / alternative parsing,
+	// a loop around the function literal cannot be continued from its body
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	fnLiteral.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 
 	return fnLiteral
 }
@@ -468,6 +708,10 @@ func (p *Parser) parseFunctionParameters() []*syntaxtree.Identifier {
 		return ids
 	}
 
+	if p.nextToken.Type != token.Identifier {
+		p.nextTokenError(token.Identifier)
+		return nil
+	}
 	p.jump()
 
 	// since there is right parenthesis, there is at least 1 parameters
@@ -479,6 +723,11 @@ func (p *Parser) parseFunctionParameters() []*syntaxtree.Identifier {
 	for p.nextToken.Type == token.Comma {
 		// jump to the comma
 		p.jump()
+
+		if p.nextToken.Type != token.Identifier {
+			p.nextTokenError(token.Identifier)
+			return nil
+		}
 		// jump to the parameter
 		p.jump()
 
@@ -488,8 +737,7 @@ func (p *Parser) parseFunctionParameters() []*syntaxtree.Identifier {
 
 	// no more comma, the next token must be a right parenthesis
 	if p.nextToken.Type != token.RightParenthesis {
-		msg := generateErrorMsg(p.currentToken.Type, token.RightParenthesis, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.RightParenthesis)
 		return nil
 	}
 	// jump to the right parenthesis
@@ -502,6 +750,10 @@ func (p *Parser) parseCallExpression(fn syntaxtree.Expr) syntaxtree.Expr {
 	exp := &syntaxtree.CallExpr{Token: p.currentToken, Function: fn}
 	exp.Arguments = p.parseCallArguments()
 
+	if p.currentToken.Type == token.RightParenthesis {
+		exp.Closing = p.currentToken
+	}
+
 	return exp
 }
 
@@ -532,8 +784,7 @@ func (p *Parser) parseCallArguments() []syntaxtree.Expr {
 
 	// no more comma, the next token must be a right parenthesis
 	if p.nextToken.Type != token.RightParenthesis {
-		msg := generateErrorMsg(p.currentToken.Type, token.RightParenthesis, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.RightParenthesis)
 		return nil
 	}
 	// jump to the right parenthesis
@@ -549,6 +800,11 @@ func (p *Parser) parseStringLiteral() syntaxtree.Expr {
 func (p *Parser) parseArrayLiteral() syntaxtree.Expr {
 	array := &syntaxtree.ArrayLiteral{Token: p.currentToken}
 	array.Elements = p.parseExpressionList(token.RightBracket)
+
+	if p.currentToken.Type == token.RightBracket {
+		array.Closing = p.currentToken
+	}
+
 	return array
 }
 
@@ -580,8 +836,7 @@ func (p *Parser) parseExpressionList(bracket token.Type) []syntaxtree.Expr {
 
 	// no more comma, the next token must be a right parenthesis
 	if p.nextToken.Type != bracket {
-		msg := generateErrorMsg(p.currentToken.Type, bracket, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(bracket)
 		return nil
 	}
 
@@ -601,13 +856,13 @@ func (p *Parser) parseIndexExpressions(left syntaxtree.Expr) syntaxtree.Expr {
 	expr.Index = p.parseExpression(Lowest)
 
 	if p.nextToken.Type != token.RightBracket {
-		msg := generateErrorMsg(p.currentToken.Type, token.RightBracket, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.RightBracket)
 		return nil
 	}
 
 	// jump to the right bracket
 	p.jump()
+	expr.Closing = p.currentToken
 
 	return expr
 }
@@ -626,8 +881,7 @@ func (p *Parser) parseHashLiteral() syntaxtree.Expr {
 
 		// expecting colon after every key
 		if p.nextToken.Type != token.Colon {
-			msg := generateErrorMsg(p.currentToken.Type, token.Colon, p.nextToken.Type)
-			p.errors = append(p.errors, msg)
+			p.nextTokenError(token.Colon)
 			return nil
 		}
 		// jump to the colon
@@ -640,8 +894,7 @@ func (p *Parser) parseHashLiteral() syntaxtree.Expr {
 
 		if p.nextToken.Type != token.RightBrace && p.nextToken.Type != token.Comma {
 			// if it is not a right brace, it should be a comma next
-			msg := generateErrorMsg(p.currentToken.Type, token.Comma, p.nextToken.Type)
-			p.errors = append(p.errors, msg)
+			p.nextTokenError(token.Comma)
 			return nil
 		}
 
@@ -654,12 +907,12 @@ func (p *Parser) parseHashLiteral() syntaxtree.Expr {
 
 	// since we have processed all of the key pairs, the last token must be a right brace
 	if p.nextToken.Type != token.RightBrace {
-		msg := generateErrorMsg(p.currentToken.Type, token.RightBrace, p.nextToken.Type)
-		p.errors = append(p.errors, msg)
+		p.nextTokenError(token.RightBrace)
 		return nil
 	}
 	// jump to the right brace
 	p.jump()
+	hash.Closing = p.currentToken
 
 	return hash
 }
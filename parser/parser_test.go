@@ -64,6 +64,28 @@ let zzz = 888;
 	}
 }
 
+func TestConstStatement(t *testing.T) {
+	p := NewParser(lexer.NewLexer("// the answer\nconst answer = 6 * 7; let other = 1;"))
+	program := p.ParseProgram()
+	if errs := p.GetErrors(); len(errs) > 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected 2 statements, but got: %d", len(program.Statements))
+	}
+
+	constStmt, ok := program.Statements[0].(*syntaxtree.LetStmt)
+	if !ok {
+		t.Fatalf("type asserting to LetStmt failed, got: %v", reflect.TypeOf(program.Statements[0]))
+	}
+	if !constStmt.Constant() || constStmt.String() != "const answer = (6 * 7);" || constStmt.Doc != "the answer" {
+		t.Errorf("unexpected const statement %q with doc %q", constStmt.String(), constStmt.Doc)
+	}
+	if program.Statements[1].(*syntaxtree.LetStmt).Constant() {
+		t.Errorf("expected the let statement not to be constant")
+	}
+}
+
 func TestReturnStatement(t *testing.T) {
 	input := `
 return x;
@@ -165,6 +187,56 @@ func TestParseIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestParseBigIntegerLiteralExpression(t *testing.T) {
+	p := NewParser(lexer.NewLexer("123456789012345678901234567890"))
+	program := p.ParseProgram()
+	if len(p.GetErrors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.GetErrors())
+	}
+
+	literal, ok := program.Statements[0].(*syntaxtree.ExpressionStmt).Expression.(*syntaxtree.IntegerLiteral)
+	if !ok {
+		t.Fatalf("expected IntegerLiteral")
+	}
+	if literal.Big == nil || literal.Big.String() != "123456789012345678901234567890" {
+		t.Errorf("expected a big value, but got %v", literal.Big)
+	}
+}
+
+func TestParseFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e3", 1000},
+		{"2.5E-1", 0.25},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.GetErrors()) > 0 {
+			t.Fatalf("unexpected parse errors: %v", p.GetErrors())
+		}
+
+		stmt := program.Statements[0].(*syntaxtree.ExpressionStmt)
+		literal, ok := stmt.Expression.(*syntaxtree.FloatLiteral)
+		if !ok {
+			t.Fatalf("expected FloatLiteral, but got %T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("expected %v, but got %v", tt.expected, literal.Value)
+		}
+	}
+
+	p := NewParser(lexer.NewLexer("1e999"))
+	p.ParseProgram()
+	if errs := p.GetErrors(); len(errs) != 1 || errs[0] != "1:1: could not parse (1e999) to float" {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input        string
@@ -339,6 +411,26 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"(5 + 5) * 2",
 			"((5 + 5) * 2)",
 		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a <= b % 2 != c >= d",
+			"((a <= (b % 2)) != (c >= d))",
+		},
+		{
+			"x = y = a || b + 1",
+			"(x = (y = (a || (b + 1))))",
+		},
+		{
+			"a[i + 1] *= f(x) - 2",
+			"((a[(i + 1)]) *= (f(x) - 2))",
+		},
 		{
 			"a * [1, 2, 3, 4][b * c] * d",
 			// index has the highest priority
@@ -571,6 +663,23 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestStringLiteralEscapes(t *testing.T) {
+	input := "\"tab\\there \\u{2764}\"; `raw\\n`"
+	program := NewParser(lexer.NewLexer(input)).ParseProgram()
+
+	expected := []string{"tab\there \u2764", `raw\n`}
+	for i, value := range expected {
+		stmt := program.Statements[i].(*syntaxtree.ExpressionStmt)
+		literal, ok := stmt.Expression.(*syntaxtree.StringLiteral)
+		if !ok {
+			t.Fatalf("expected type StringLiteral, but got %T", stmt.Expression)
+		}
+		if literal.Value != value {
+			t.Errorf("expected literal value %q, but got %q", value, literal.Value)
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 	l := lexer.NewLexer(input)
@@ -803,3 +912,274 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeSpans(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b
+};
+add(1, [2, 3][0]);`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	if len(p.GetErrors()) > 0 {
+		t.Fatalf("unexpected parse errors: %v", p.GetErrors())
+	}
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected 2 statements, but got %d", len(program.Statements))
+	}
+
+	letStmt := program.Statements[0].(*syntaxtree.LetStmt)
+	fnLiteral := letStmt.Value.(*syntaxtree.FunctionLiteral)
+	infix := fnLiteral.Body.Statements[0].(*syntaxtree.ExpressionStmt).Expression
+	call := program.Statements[1].(*syntaxtree.ExpressionStmt).Expression.(*syntaxtree.CallExpr)
+	index := call.Arguments[1]
+
+	tests := []struct {
+		node          syntaxtree.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{program, "1:1", "4:18"},
+		{letStmt, "1:1", "3:2"},
+		{letStmt.Name, "1:5", "1:8"},
+		{fnLiteral, "1:11", "3:2"},
+		{fnLiteral.Body, "1:20", "3:2"},
+		{infix, "2:3", "2:8"},
+		{call, "4:1", "4:18"},
+		{index, "4:8", "4:17"},
+	}
+
+	for _, tt := range tests {
+		if start := tt.node.Pos().String(); start != tt.expectedStart {
+			t.Errorf("expected %q to start at %s, but got %s", tt.node.String(), tt.expectedStart, start)
+		}
+		if end := tt.node.End().String(); end != tt.expectedEnd {
+			t.Errorf("expected %q to end at %s, but got %s", tt.node.String(), tt.expectedEnd, end)
+		}
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	input := `
+while (x < 10) { let x = x + 1; if (x == 5) { break; } }
+for (item in [1, 2]) { continue; item }
+`
+	p := NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if errs := p.GetErrors(); len(errs) > 0 {
+		t.Fatalf("unexpected errors %q", errs)
+	}
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected 2 statements, but got %d", len(program.Statements))
+	}
+
+	while, ok := program.Statements[0].(*syntaxtree.WhileStmt)
+	if !ok {
+		t.Fatalf("expected type WhileStmt, but got %T", program.Statements[0])
+	}
+	if while.Condition.String() != "(x < 10)" || len(while.Body.Statements) != 2 {
+		t.Errorf("unexpected while loop %s", while.String())
+	}
+
+	loop, ok := program.Statements[1].(*syntaxtree.ForStmt)
+	if !ok {
+		t.Fatalf("expected type ForStmt, but got %T", program.Statements[1])
+	}
+	if loop.Variable.Value != "item" || loop.Iterable.String() != "[1, 2]" || len(loop.Body.Statements) != 2 {
+		t.Errorf("unexpected for loop %s", loop.String())
+	}
+	if branch, ok := loop.Body.Statements[0].(*syntaxtree.BranchStmt); !ok || branch.Token.Type != token.Continue {
+		t.Errorf("expected a continue statement, but got %s", loop.Body.Statements[0].String())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements int
+	}{
+		{
+			input:              "let = 5; let y = 6;",
+			expectedErrors:     []string{"1:5: expected (Identifier) after (Let), but got (=)"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "let x 5; x;",
+			expectedErrors:     []string{"1:7: expected (=) after (Identifier), but got (Int \"5\")"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "let x = (1 + ; let y = 2; y",
+			expectedErrors:     []string{"1:14: expected an expression, but got (;)"},
+			expectedStatements: 2,
+		},
+		{
+			input:              "foo(1, 2 3); bar();",
+			expectedErrors:     []string{"1:10: expected ()) after (Int), but got (Int \"3\")"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "let f = fn() { 1 + }; 5;",
+			expectedErrors:     []string{"1:20: expected an expression, but got (})"},
+			expectedStatements: 2,
+		},
+		{
+			input:              "a + b = 1; 2;",
+			expectedErrors:     []string{"1:1: cannot assign to (a + b)"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "!) = 1; 2;",
+			expectedErrors:     []string{"1:2: expected an expression, but got ())"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "f() += 1",
+			expectedErrors:     []string{"1:1: cannot assign to f()"},
+			expectedStatements: 0,
+		},
+		{
+			input:              "let f = fn() { let = 1; 2 }; f();",
+			expectedErrors:     []string{"1:20: expected (Identifier) after (Let), but got (=)"},
+			expectedStatements: 2,
+		},
+		{
+			input:              "if (true) { 1",
+			expectedErrors:     []string{"1:14: expected (}) to close the block, but got (end of input)"},
+			expectedStatements: 0,
+		},
+		{
+			input: "let a = ; let b = ; let c = 3;",
+			expectedErrors: []string{
+				"1:9: expected an expression, but got (;)",
+				"1:19: expected an expression, but got (;)",
+			},
+			expectedStatements: 1,
+		},
+		{
+			input:              "break; let x = 1;",
+			expectedErrors:     []string{"1:1: break is not in a loop"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "while (true) { let f = fn() { continue; }; }",
+			expectedErrors:     []string{"1:31: continue is not in a loop"},
+			expectedStatements: 1,
+		},
+		{
+			input:              "for (x of xs) { x }",
+			expectedErrors:     []string{"1:8: expected (In) after (Identifier), but got (Identifier \"of\")"},
+			expectedStatements: 0,
+		},
+		{
+			input:              "let s = \"abc;\nlet t = 1;",
+			expectedErrors:     []string{"1:9: unterminated string"},
+			expectedStatements: 0,
+		},
+		{
+			input:              `puts("a\qb"); 2`,
+			expectedErrors:     []string{`1:8: unknown escape sequence \q`},
+			expectedStatements: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+
+		errs := p.GetErrors()
+		if !reflect.DeepEqual(errs, tt.expectedErrors) {
+			t.Errorf("input %q: expected errors %q, but got %q", tt.input, tt.expectedErrors, errs)
+		}
+		if len(program.Statements) != tt.expectedStatements {
+			t.Errorf("input %q: expected %d statements, but got %d (%s)",
+				tt.input, tt.expectedStatements, len(program.Statements), program.String())
+		}
+	}
+}
+
+func TestMalformedStatementsAreDropped(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedStatements string
+	}{
+		{"while(#){0}", ""},
+		{"while (#) { 0; }; 1", "1"},
+		{"for (x in #) { x }; 2", "2"},
+		{"if (#) { 1 } else { 2 }; 3", "3"},
+		// the malformed statement is dropped from the body of the function
+		{"let f = fn() { while (#) { 0 } }; 4", "let f = fn() ;4"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(p.GetErrors()) != 1 {
+			t.Errorf("input %q: expected a single error, but got %q", tt.input, p.GetErrors())
+		}
+		// rendering a statement with missing parts would panic
+		if actual := program.String(); actual != tt.expectedStatements {
+			t.Errorf("input %q: expected the statements %q, but got %q", tt.input, tt.expectedStatements, actual)
+		}
+	}
+}
+
+func TestParseErrorFields(t *testing.T) {
+	p := NewParser(lexer.NewLexer("let x 5;"))
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("expected 1 error, but got %d", len(p.Errors()))
+	}
+
+	err := p.Errors()[0]
+	if err.Expected != token.Assign || err.Actual != token.Int {
+		t.Errorf("expected (%s) and actual (%s), but got (%s) and (%s)",
+			token.Assign, token.Int, err.Expected, err.Actual)
+	}
+	if err.Pos.Line != 1 || err.Pos.Column != 7 {
+		t.Errorf("expected error at 1:7, but got %s", err.Pos)
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `
+// add returns the sum
+// of a and b
+let add = fn(a, b) { a + b };
+
+// unrelated comment
+
+let none = 1; // trailing comment of none
+let trailing = 2;
+/*
+   block comment
+   of block
+*/
+let block = 3;
+`
+	expected := map[string]string{
+		"add":      "add returns the sum\nof a and b",
+		"none":     "",
+		"trailing": "",
+		"block":    "block comment\nof block",
+	}
+
+	for _, options := range [][]lexer.Option{nil, {lexer.WithComments()}} {
+		p := NewParser(lexer.NewLexer(input, options...))
+		program := p.ParseProgram()
+		if errs := p.GetErrors(); len(errs) > 0 {
+			t.Fatalf("unexpected errors %q", errs)
+		}
+
+		for _, statement := range program.Statements {
+			stmt := statement.(*syntaxtree.LetStmt)
+			if doc := expected[stmt.Name.Value]; stmt.Doc != doc {
+				t.Errorf("%s: expected doc %q, but got %q", stmt.Name.Value, doc, stmt.Doc)
+			}
+		}
+	}
+}
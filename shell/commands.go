@@ -0,0 +1,143 @@
+package shell
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/HakanSunay/gohil/lexer"
+	"github.com/HakanSunay/gohil/syntaxtree"
+	"github.com/HakanSunay/gohil/token"
+)
+
+// commandPrefix starts the meta-commands, which inspect and manage the session instead of being executed
+const commandPrefix = ":"
+
+// command is a meta-command of the shell, its argument is the rest of the input
+type command struct {
+	name  string
+	args  string
+	usage string
+	run   func(s *session, arg string) error
+}
+
+// commands are listed by :help in this order, they are set in init,
+// because :help refers to them
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "env", usage: "list the bindings and their types", run: (*session).env},
+		{name: "type", args: "expr", usage: "evaluate the expression and print the type of its value", run: (*session).typeOf},
+		{name: "ast", args: "expr", usage: "print the syntax tree of the input", run: (*session).ast},
+		{name: "tokens", args: "expr", usage: "print the tokens of the input", run: (*session).tokens},
+		{name: "load", args: "file", usage: "execute the file in the session", run: (*session).load},
+		{name: "save", args: "file", usage: "write the inputs that were executed without errors to the file", run: (*session).save},
+		{name: "reset", usage: "discard the bindings and the inputs of the session", run: (*session).resetCommand},
+		{name: "help", usage: "list the commands", run: (*session).help},
+	}
+}
+
+// command runs the meta-command in the input, e.g. ":type 1 + 2"
+func (s *session) command(input string) {
+	name, arg := input[len(commandPrefix):], ""
+	if i := strings.IndexAny(name, " \t\n"); i >= 0 {
+		name, arg = name[:i], strings.TrimSpace(name[i:])
+	}
+
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		if c.args != "" && arg == "" {
+			s.eprintln(fmt.Sprintf("usage: %s%s %s", commandPrefix, c.name, c.args))
+			return
+		}
+		if err := c.run(s, arg); err != nil {
+			s.printError(err)
+		}
+		return
+	}
+
+	s.eprintln(fmt.Sprintf("unknown command %s%s, type %shelp for the list of commands", commandPrefix, name, commandPrefix))
+}
+
+func (s *session) env(string) error {
+	for _, name := range s.interp.Names() {
+		value, _ := s.interp.Get(name)
+		s.println(fmt.Sprintf("%s: %s", name, value.Type()))
+	}
+
+	return nil
+}
+
+func (s *session) typeOf(arg string) error {
+	program, ok := s.parse(arg)
+	if !ok {
+		return nil
+	}
+
+	result, err := evalInterruptibly(s.ctx, s.interp, program)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		// let statements do not produce values
+		s.println("no value")
+		return nil
+	}
+
+	s.println(string(result.Type()))
+	return nil
+}
+
+func (s *session) ast(arg string) error {
+	program, ok := s.parse(arg)
+	if !ok {
+		return nil
+	}
+
+	return syntaxtree.Fprint(s.stdout, program)
+}
+
+func (s *session) tokens(arg string) error {
+	l := lexer.NewLexer(arg, lexer.WithComments())
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		s.println(fmt.Sprintf("%-6s %-12s %q", tok.Pos, tok.Type, tok.Literal))
+	}
+
+	return nil
+}
+
+func (s *session) load(path string) error {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	s.execute(string(source))
+	return nil
+}
+
+func (s *session) save(path string) error {
+	var source strings.Builder
+	for _, input := range s.inputs {
+		source.WriteString(input + "\n")
+	}
+
+	return ioutil.WriteFile(path, []byte(source.String()), 0644)
+}
+
+func (s *session) resetCommand(string) error {
+	s.reset()
+	return nil
+}
+
+func (s *session) help(string) error {
+	for _, c := range commands {
+		s.println(fmt.Sprintf("%-16s %s", commandPrefix+c.name+" "+c.args, c.usage))
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package shell
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/HakanSunay/gohil/completion"
+)
+
+// completer completes the input of the shell on Tab, names are looked up
+// in the current interpreter of the session, which changes on :reset
+type completer struct {
+	session *session
+}
+
+// Do implements readline.AutoCompleter, the candidates are returned
+// without the prefix they share with the line
+func (c *completer) Do(line []rune, pos int) ([][]rune, int) {
+	result := c.complete(string(line[:pos]))
+
+	suffixes := make([][]rune, len(result.Candidates))
+	for i, candidate := range result.Candidates {
+		suffixes[i] = []rune(strings.TrimPrefix(candidate, result.Prefix))
+	}
+
+	return suffixes, utf8.RuneCountInString(result.Prefix)
+}
+
+// complete completes the names of the meta-commands and the gohil source
+func (c *completer) complete(before string) completion.Result {
+	if strings.HasPrefix(before, commandPrefix) && !strings.ContainsAny(before, " \t") {
+		result := completion.Result{Prefix: before}
+		for _, cmd := range commands {
+			if name := commandPrefix + cmd.name; strings.HasPrefix(name, before) {
+				result.Candidates = append(result.Candidates, name+" ")
+			}
+		}
+		return result
+	}
+
+	return completion.Complete(c.session.interp, before)
+}
@@ -0,0 +1,135 @@
+package shell
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chzyer/readline"
+
+	"github.com/HakanSunay/gohil/lexer"
+	"github.com/HakanSunay/gohil/token"
+)
+
+const (
+	prompt = "GOHIL=> "
+	// continuationPrompt is shown while the input is incomplete, it has the width of prompt
+	continuationPrompt = "....... "
+)
+
+// historyFile is the file in the home directory, where the history is kept across sessions
+const historyFile = ".gohil_history"
+
+// lineReader reads the input of the shell line by line
+type lineReader interface {
+	Readline() (string, error)
+	SetPrompt(prompt string)
+	Close() error
+}
+
+// newLineReader returns a line editor with history, completion and highlighting, when the reader is a terminal,
+// otherwise the lines are read as they are, which is what pipes and tests need
+func newLineReader(reader io.Reader, stdout io.Writer, stderr io.Writer, painter readline.Painter, completer readline.AutoCompleter) (lineReader, error) {
+	if file, ok := reader.(*os.File); ok && readline.IsTerminal(int(file.Fd())) {
+		return readline.NewEx(&readline.Config{
+			Prompt:          prompt,
+			HistoryFile:     historyPath(),
+			InterruptPrompt: "^C",
+			AutoComplete:    completer,
+			Painter:         painter,
+			Stdin:           file,
+			Stdout:          stdout,
+			Stderr:          stderr,
+		})
+	}
+
+	return &scannerReader{scanner: bufio.NewScanner(reader), writer: stdout, prompt: prompt}, nil
+}
+
+// historyPath returns the path of the history file, an empty path disables the history
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, historyFile)
+}
+
+// scannerReader reads lines from a plain reader, the prompt is written before every line
+type scannerReader struct {
+	scanner *bufio.Scanner
+	writer  io.Writer
+	prompt  string
+}
+
+func (s *scannerReader) Readline() (string, error) {
+	if _, err := io.WriteString(s.writer, s.prompt); err != nil {
+		return "", err
+	}
+
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+
+	return s.scanner.Text(), nil
+}
+
+func (s *scannerReader) SetPrompt(prompt string) {
+	s.prompt = prompt
+}
+
+func (s *scannerReader) Close() error {
+	return nil
+}
+
+// readInput reads lines until they form a complete input, using the continuation prompt
+// for every line after the first one. Pressing Ctrl-C discards the lines read so far
+// and returns readline.ErrInterrupt.
+func readInput(lines lineReader) (string, error) {
+	var input strings.Builder
+
+	lines.SetPrompt(prompt)
+	for {
+		line, err := lines.Readline()
+		if err == io.EOF && input.Len() > 0 {
+			// the input ended in the middle of an expression, let the parser report it
+			return input.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		input.WriteString(line)
+		input.WriteByte('\n')
+
+		if isComplete(input.String()) {
+			return input.String(), nil
+		}
+		lines.SetPrompt(continuationPrompt)
+	}
+}
+
+// isComplete reports whether the source can be parsed as it is, or more lines are expected,
+// because it has unbalanced braces, brackets or parentheses or an unterminated string.
+// Too many closing delimiters cannot be fixed by reading more, so such input is complete.
+func isComplete(source string) bool {
+	depth := 0
+
+	l := lexer.NewLexer(source)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LeftParenthesis, token.LeftBrace, token.LeftBracket:
+			depth++
+		case token.RightParenthesis, token.RightBrace, token.RightBracket:
+			depth--
+		}
+	}
+
+	return depth <= 0 && !l.Unterminated()
+}
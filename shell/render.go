@@ -0,0 +1,142 @@
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/HakanSunay/gohil/object"
+)
+
+const (
+	// maxInlineWidth is the width up to which arrays and hashes are rendered on a single line
+	maxInlineWidth = 80
+	// maxRenderedElements is the number of elements (or pairs) that are rendered of an array (or hash)
+	maxRenderedElements = 100
+
+	indentation = "  "
+)
+
+// render renders the result of an input, unlike Inspect it puts the elements of arrays and hashes
+// that do not fit on a line on their own lines, sorts the pairs of hashes and truncates long ones
+func render(obj object.Object) string {
+	var builder strings.Builder
+	renderIndented(&builder, obj, 0, make(map[object.Object]bool))
+
+	return builder.String()
+}
+
+// renderIndented renders the object at the given depth, visiting holds the arrays and hashes that contain it,
+// which are rendered as [...] or {...} when they contain themselves, see renderInline
+func renderIndented(builder *strings.Builder, obj object.Object, depth int, visiting map[object.Object]bool) {
+	inline := renderInline(obj, visiting)
+	if len(inline)+depth*len(indentation) <= maxInlineWidth {
+		builder.WriteString(inline)
+		return
+	}
+
+	// the entries of arrays are their elements, the entries of hashes are labeled with their keys
+	var open, close string
+	var labels []string
+	var values []object.Object
+	switch obj := obj.(type) {
+	case *object.Array:
+		open, close = "[", "]"
+		labels, values = make([]string, len(obj.Elements)), obj.Elements
+	case *object.Hash:
+		open, close = "{", "}"
+		for _, pair := range sortedPairs(obj) {
+			labels = append(labels, renderInline(pair.Key, visiting)+": ")
+			values = append(values, pair.Value)
+		}
+	default:
+		builder.WriteString(inline)
+		return
+	}
+
+	visiting[obj] = true
+	defer delete(visiting, obj)
+
+	builder.WriteString(open + "\n")
+
+	shown, hidden := truncate(len(values))
+	for i := 0; i < shown; i++ {
+		builder.WriteString(strings.Repeat(indentation, depth+1) + labels[i])
+		renderIndented(builder, values[i], depth+1, visiting)
+		builder.WriteString(",\n")
+	}
+	if hidden > 0 {
+		builder.WriteString(strings.Repeat(indentation, depth+1) + moreElements(hidden) + "\n")
+	}
+
+	builder.WriteString(strings.Repeat(indentation, depth) + close)
+}
+
+// renderInline renders the object on a single line,
+// an array or a hash that contains itself is rendered as [...] or {...} inside of itself
+func renderInline(obj object.Object, visiting map[object.Object]bool) string {
+	var parts []string
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		if visiting[obj] {
+			return "[...]"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		shown, hidden := truncate(len(obj.Elements))
+		for _, element := range obj.Elements[:shown] {
+			parts = append(parts, renderInline(element, visiting))
+		}
+		if hidden > 0 {
+			parts = append(parts, moreElements(hidden))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case *object.Hash:
+		if visiting[obj] {
+			return "{...}"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		pairs := sortedPairs(obj)
+		shown, hidden := truncate(len(pairs))
+		for _, pair := range pairs[:shown] {
+			parts = append(parts, renderInline(pair.Key, visiting)+": "+renderInline(pair.Value, visiting))
+		}
+		if hidden > 0 {
+			parts = append(parts, moreElements(hidden))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return obj.Inspect()
+	}
+}
+
+// sortedPairs returns the pairs of the hash sorted by their keys, so that the output is stable
+func sortedPairs(hash *object.Hash) []object.HashPair {
+	pairs := make([]object.HashPair, 0, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+	})
+
+	return pairs
+}
+
+// truncate splits the number of elements into the ones that are rendered and the ones that are not
+func truncate(length int) (shown int, hidden int) {
+	if length > maxRenderedElements {
+		return maxRenderedElements, length - maxRenderedElements
+	}
+
+	return length, 0
+}
+
+func moreElements(hidden int) string {
+	return fmt.Sprintf("... %d more", hidden)
+}
@@ -0,0 +1,77 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/HakanSunay/gohil/interpreter"
+	"github.com/HakanSunay/gohil/logger"
+	"github.com/HakanSunay/gohil/object"
+	"github.com/HakanSunay/gohil/parser"
+)
+
+// Exit codes returned by RunScript, ExitUsageError is used for invalid command line flags
+const (
+	ExitOK           = 0
+	ExitRuntimeError = 1
+	ExitParseError   = 2
+	ExitIOError      = 3
+	ExitUsageError   = 4
+)
+
+// argsIdentifier is the name under which the script arguments are exposed
+const argsIdentifier = "args"
+
+// RunScript reads the whole gohil script found at path, lexes, parses and evaluates it
+// in a fresh interpreter. The given args are exposed to the program as an array of strings
+// bound to the "args" identifier and print writes to stdout. Further interpreter options
+// (e.g. the backend) can be passed with opts.
+// The returned value is meant to be used as the process exit code.
+func RunScript(ctx context.Context, path string, args []string, stdout io.Writer, stderr io.Writer, opts ...interpreter.Option) int {
+	log := logger.GetFromContext(ctx)
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Errorf("Unable to read script %s: %v", path, err)
+		fmt.Fprintf(stderr, "%s: %v\n", path, err)
+		return ExitIOError
+	}
+
+	interp := interpreter.NewInterpreter(append([]interpreter.Option{
+		interpreter.WithStdout(stdout),
+		interpreter.WithStderr(stderr),
+		interpreter.WithGlobal(argsIdentifier, newArgsArray(args)),
+	}, opts...)...)
+
+	_, err = interp.Run(ctx, string(source))
+	switch err := err.(type) {
+	case nil:
+		return ExitOK
+	case parser.ErrorList:
+		log.Errorf("Parse of %s encountered the following errors: %v", path, err.Error())
+		for _, parseErr := range err {
+			fmt.Fprintf(stderr, "%s:%s\n", path, parseErr.Error())
+		}
+		return ExitParseError
+	case *object.Error:
+		log.Errorf("Script %s terminated with error: %s", path, err.Message)
+		fmt.Fprintln(stderr, err.Traceback(path))
+		return ExitRuntimeError
+	default:
+		log.Errorf("Script %s terminated with error: %v", path, err)
+		fmt.Fprintf(stderr, "%s: %v\n", path, err)
+		return ExitRuntimeError
+	}
+}
+
+// newArgsArray converts the command line arguments to a gohil array of strings
+func newArgsArray(args []string) *object.Array {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HakanSunay/gohil/interpreter"
+)
+
+func TestRunScript(t *testing.T) {
+	tests := []struct {
+		name           string
+		source         string
+		args           []string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{
+			name: "multi-line function",
+			source: `let add = fn(a, b) {
+	a + b
+};
+print(add(1, 2));`,
+			expectedCode:   ExitOK,
+			expectedStdout: "3\n",
+		},
+		{
+			name:           "arguments",
+			source:         `print(len(args)); print(args[1]);`,
+			args:           []string{"first", "second"},
+			expectedCode:   ExitOK,
+			expectedStdout: "2\nsecond\n",
+		},
+		{
+			name:           "runtime error",
+			source:         "let x = 1;\ny;",
+			expectedCode:   ExitRuntimeError,
+			expectedStderr: "identifier not found: y",
+		},
+		{
+			name:           "self-referencing values",
+			source:         `let a = [1]; a[0] = a; let h = {}; h["self"] = h; print(a); print(h);`,
+			expectedCode:   ExitOK,
+			expectedStdout: "[[...]]\n{self: {...}}\n",
+		},
+		{
+			name:           "parse error",
+			source:         "let = 5;",
+			expectedCode:   ExitParseError,
+			expectedStderr: "script.ghl:1:5: ",
+		},
+	}
+
+	for _, backend := range []interpreter.Backend{interpreter.BackendEval, interpreter.BackendVM} {
+		for _, tt := range tests {
+			t.Run(string(backend)+"/"+tt.name, func(t *testing.T) {
+				path := writeScript(t, tt.source)
+				defer os.RemoveAll(filepath.Dir(path))
+
+				var stdout, stderr bytes.Buffer
+				code := RunScript(context.Background(), path, tt.args, &stdout, &stderr, interpreter.WithBackend(backend))
+				if code != tt.expectedCode {
+					t.Errorf("expected exit code %d, but got %d (stderr: %q)", tt.expectedCode, code, stderr.String())
+				}
+				if tt.expectedStdout != "" && stdout.String() != tt.expectedStdout {
+					t.Errorf("expected stdout %q, but got %q", tt.expectedStdout, stdout.String())
+				}
+				if !strings.Contains(stderr.String(), tt.expectedStderr) {
+					t.Errorf("expected stderr to contain %q, but got %q", tt.expectedStderr, stderr.String())
+				}
+			})
+		}
+	}
+}
+
+func TestRunScriptMissingFile(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := RunScript(context.Background(), "does-not-exist.ghl", nil, &stdout, &stderr)
+	if code != ExitIOError {
+		t.Errorf("expected exit code %d, but got %d", ExitIOError, code)
+	}
+}
+
+func writeScript(t *testing.T, source string) string {
+	dir, err := ioutil.TempDir("", "gohil")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	path := filepath.Join(dir, "script.ghl")
+	if err := ioutil.WriteFile(path, []byte(source), 0600); err != nil {
+		t.Fatalf("unable to write script: %v", err)
+	}
+
+	return path
+}
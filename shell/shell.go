@@ -1,62 +1,186 @@
 package shell
 
 import (
-	"bufio"
 	"context"
 	"io"
+	"os"
+	"os/signal"
+	"strings"
 
-	"github.com/HakanSunay/gohil/eval"
+	"github.com/chzyer/readline"
+	"github.com/sirupsen/logrus"
+
+	"github.com/HakanSunay/gohil/interpreter"
 	"github.com/HakanSunay/gohil/lexer"
 	"github.com/HakanSunay/gohil/logger"
 	"github.com/HakanSunay/gohil/object"
 	"github.com/HakanSunay/gohil/parser"
+	"github.com/HakanSunay/gohil/syntaxtree"
 )
 
-const prompt = "GOHIL=> "
-
-func Start(ctx context.Context, reader io.Reader, writer io.Writer) {
+// Start runs the interactive shell, every input is executed by the same interpreter.
+// An input spans multiple lines while its braces, brackets or parentheses are unbalanced
+// or a string is unterminated. When the reader is a terminal, lines can be edited, Tab completes names,
+// the history is kept across sessions and Ctrl-C cancels the current input or evaluation without exiting.
+// Inputs starting with a colon are meta-commands, :help lists them.
+// Results are written to stdout, errors to stderr, both are coloured only when they are terminals.
+// The output of print and eprint goes to stdout and stderr as well, unless opts say otherwise.
+func Start(ctx context.Context, reader io.Reader, stdout io.Writer, stderr io.Writer, opts ...interpreter.Option) {
 	log := logger.GetFromContext(ctx)
 
-	scanner := bufio.NewScanner(reader)
-	environment := object.NewEnvironment()
+	s := newSession(ctx, stdout, stderr, opts)
+
+	lines, err := newLineReader(reader, stdout, stderr, s.stdoutPalette, &completer{session: s})
+	if err != nil {
+		log.Errorf("Unable to initialize the line editor: %v", err)
+		return
+	}
+	defer lines.Close()
 
 	for {
-		_, err := io.WriteString(writer, prompt)
-		if err != nil {
-			log.Errorf("Unable to redirect prompt output")
+		input, err := readInput(lines)
+		if err == readline.ErrInterrupt {
 			continue
 		}
-
-		scanned := scanner.Scan()
-		if !scanned {
+		if err != nil {
+			if err != io.EOF {
+				log.Errorf("Unable to read input: %v", err)
+			}
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.NewLexer(line)
-		p := parser.NewParser(l)
-		program := p.ParseProgram()
-
-		if len(p.GetErrors()) > 0 {
-			log.Errorf("Parse encountered the following erros: %v", p.GetErrors())
-			for _, errorMsg := range p.GetErrors() {
-				_, err := io.WriteString(writer, errorMsg+"\n")
-				if err != nil {
-					log.Errorf("Unable to redirect error output")
-				}
-			}
+		switch trimmed := strings.TrimSpace(input); {
+		case trimmed == "":
 			continue
+		case strings.HasPrefix(trimmed, commandPrefix):
+			s.command(trimmed)
+		default:
+			s.execute(input)
 		}
+	}
+}
 
-		result := eval.Eval(program, environment)
-		if result == nil {
-			log.Errorf("Unsupported evaluation type")
-			continue
-		}
+// session is the state of a running shell
+type session struct {
+	ctx context.Context
+	log logrus.FieldLogger
 
-		_, err = io.WriteString(writer, result.Inspect()+"\n")
-		if err != nil {
-			log.Errorf("Unable to redirect result output")
+	stdout        io.Writer
+	stderr        io.Writer
+	stdoutPalette palette
+	stderrPalette palette
+
+	// opts are kept to create a fresh interpreter on :reset
+	opts   []interpreter.Option
+	interp *interpreter.Interpreter
+
+	// inputs are the inputs that were executed without errors, in order, :save writes them
+	inputs []string
+}
+
+func newSession(ctx context.Context, stdout io.Writer, stderr io.Writer, opts []interpreter.Option) *session {
+	s := &session{
+		ctx:           ctx,
+		log:           logger.GetFromContext(ctx),
+		stdout:        stdout,
+		stderr:        stderr,
+		stdoutPalette: newPalette(stdout),
+		stderrPalette: newPalette(stderr),
+		opts: append([]interpreter.Option{
+			interpreter.WithStdout(stdout),
+			interpreter.WithStderr(stderr),
+		}, opts...),
+	}
+	s.reset()
+
+	return s
+}
+
+// reset discards the bindings and the inputs of the session
+func (s *session) reset() {
+	s.interp = interpreter.NewInterpreter(s.opts...)
+	s.inputs = nil
+}
+
+// execute parses and evaluates the input and writes its result,
+// the input is remembered if it was executed successfully
+func (s *session) execute(input string) {
+	program, ok := s.parse(input)
+	if !ok {
+		return
+	}
+
+	result, err := evalInterruptibly(s.ctx, s.interp, program)
+	if err != nil {
+		s.printError(err)
+		return
+	}
+	s.inputs = append(s.inputs, strings.TrimSpace(input))
+
+	if result == nil {
+		s.log.Errorf("Unsupported evaluation type")
+		return
+	}
+
+	s.println(render(result))
+}
+
+// parse parses the input, the parse errors are written and ok is false if there are any
+func (s *session) parse(input string) (program *syntaxtree.Program, ok bool) {
+	p := parser.NewParser(lexer.NewLexer(input))
+	program = p.ParseProgram()
+
+	if len(p.GetErrors()) > 0 {
+		s.log.Errorf("Parse encountered the following erros: %v", p.GetErrors())
+		for _, errorMsg := range p.GetErrors() {
+			s.eprintln(errorMsg)
 		}
+		return nil, false
+	}
+
+	return program, true
+}
+
+func (s *session) printError(err error) {
+	output := err.Error()
+	if errObj, ok := err.(*object.Error); ok {
+		output = errObj.Traceback("")
 	}
+
+	s.eprintln(output)
+}
+
+// println writes the output of the session to stdout
+func (s *session) println(output string) {
+	if _, err := io.WriteString(s.stdout, output+"\n"); err != nil {
+		s.log.Errorf("Unable to redirect output")
+	}
+}
+
+// eprintln writes the errors of the session to stderr, in the error style
+func (s *session) eprintln(output string) {
+	if _, err := io.WriteString(s.stderr, s.stderrPalette.paint(styleError, output)+"\n"); err != nil {
+		s.log.Errorf("Unable to redirect error output")
+	}
+}
+
+// evalInterruptibly evaluates the program, an interrupt (Ctrl-C) cancels the evaluation
+// instead of terminating the process
+func evalInterruptibly(ctx context.Context, interp *interpreter.Interpreter, program *syntaxtree.Program) (object.Object, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return interp.Eval(ctx, program)
 }
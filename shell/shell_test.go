@@ -0,0 +1,264 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HakanSunay/gohil/object"
+)
+
+func TestIsComplete(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected bool
+	}{
+		{"let x = 5;", true},
+		{"let add = fn(a, b) {", false},
+		{"let add = fn(a, b) {\n a + b\n};", true},
+		{"[1, 2,", false},
+		{"add(1,\n", false},
+		{`"unterminated`, false},
+		{"\"multi\nline\"", true},
+		{`"{"`, true},
+		{"`raw\nstring", false},
+		{`"escaped \"`, false},
+		{`"bad \q escape"`, true},
+		{"1 /* open\ncomment", false},
+		{"1 // the end (", true},
+		{"}", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		if complete := isComplete(tt.source); complete != tt.expected {
+			t.Errorf("%q: expected complete=%t, but got %t", tt.source, tt.expected, complete)
+		}
+	}
+}
+
+func TestStartMultiLineInput(t *testing.T) {
+	input := strings.Join([]string{
+		"let add = fn(a, b) {",
+		"  a + b",
+		"};",
+		"add(1,",
+		"2)",
+		`"two`,
+		`lines"`,
+		"let broken = [1,",
+	}, "\n")
+
+	var output bytes.Buffer
+	Start(context.Background(), strings.NewReader(input), &output, &output)
+
+	expected := prompt + continuationPrompt + continuationPrompt +
+		prompt + continuationPrompt + "3\n" +
+		prompt + continuationPrompt + "two\nlines\n" +
+		prompt
+	if !strings.HasPrefix(output.String(), expected) {
+		t.Fatalf("expected output to start with %q, but got %q", expected, output.String())
+	}
+
+	// the input ended in the middle of the array, the parser reports it
+	if rest := strings.TrimPrefix(output.String(), expected); !strings.Contains(rest, "end of input") {
+		t.Errorf("expected a parse error, but got %q", rest)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gohil")
+	if err != nil {
+		t.Fatalf("unable to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.ghl")
+
+	tests := []struct {
+		name     string
+		input    []string
+		expected []string
+	}{
+		{
+			name:     "env",
+			input:    []string{"let x = 1;", "let s = \"gohil\";", ":env"},
+			expected: []string{"eprint: Builtin\nprint: Builtin\ns: String\nx: Integer\n"},
+		},
+		{
+			name:     "type",
+			input:    []string{":type 1.5 * 2", ":type let y = 1;", ":type"},
+			expected: []string{"Float\n", "no value\n", "usage: :type expr\n"},
+		},
+		{
+			name:     "ast",
+			input:    []string{":ast -x"},
+			expected: []string{"Program 1:1\n  Statements[0]: ExpressionStmt 1:1\n    Expression: PrefixExpr 1:1\n      Operator: \"-\"\n      Right: Identifier 1:2\n        Value: \"x\"\n"},
+		},
+		{
+			name:     "tokens",
+			input:    []string{`:tokens f("a")`},
+			expected: []string{"1:1    Identifier   \"f\"\n1:2    (            \"(\"\n1:3    String       \"a\"\n1:6    )            \")\"\n"},
+		},
+		{
+			name:     "save, reset and load",
+			input:    []string{"let x = 20;", "y;", "x + 1", ":save " + path, ":reset", "x", ":load " + path, "x * 2"},
+			expected: []string{"21\n", "identifier not found: x", "21\n", "40\n"},
+		},
+		{
+			name:     "unknown",
+			input:    []string{":nope"},
+			expected: []string{"unknown command :nope"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			Start(context.Background(), strings.NewReader(strings.Join(tt.input, "\n")), &output, &output)
+
+			rest := output.String()
+			for _, expected := range tt.expected {
+				i := strings.Index(rest, expected)
+				if i < 0 {
+					t.Fatalf("expected %q in the rest of the output %q", expected, rest)
+				}
+				rest = rest[i+len(expected):]
+			}
+		})
+	}
+}
+
+func TestCompleter(t *testing.T) {
+	s := newSession(context.Background(), ioutil.Discard, ioutil.Discard, nil)
+	s.execute("let answer = 42;")
+	c := &completer{session: s}
+
+	tests := []struct {
+		line           string
+		expected       []string
+		expectedLength int
+	}{
+		{"ans", []string{"wer"}, 3},
+		{"app", []string{"end"}, 3},
+		{":re", []string{"set "}, 3},
+		{":type ans", []string{"wer"}, 3},
+	}
+
+	for _, tt := range tests {
+		suffixes, length := c.Do([]rune(tt.line), len([]rune(tt.line)))
+
+		var actual []string
+		for _, suffix := range suffixes {
+			actual = append(actual, string(suffix))
+		}
+		if !reflect.DeepEqual(actual, tt.expected) || length != tt.expectedLength {
+			t.Errorf("%q: expected %v (%d), but got %v (%d)", tt.line, tt.expected, tt.expectedLength, actual, length)
+		}
+	}
+
+	// names bound before :reset are gone
+	s.reset()
+	if suffixes, _ := c.Do([]rune("ans"), 3); len(suffixes) != 0 {
+		t.Errorf("expected no candidates after reset, but got %d", len(suffixes))
+	}
+}
+
+func TestStartSeparatesErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	Start(context.Background(), strings.NewReader("1 + 1\nlet = 1;\nx\n:nope\neprint(3)"), &stdout, &stderr)
+
+	if expected := prompt + "2\n" + strings.Repeat(prompt, 4) + "null\n" + prompt; stdout.String() != expected {
+		t.Errorf("expected stdout %q, but got %q", expected, stdout.String())
+	}
+
+	// buffers are not terminals, therefore the errors are not coloured
+	expected := "1:5: expected (Identifier) after (Let), but got (=)\n" +
+		"ERROR: identifier not found: x\n    at <main> (1:1)\n" +
+		"unknown command :nope, type :help for the list of commands\n" +
+		"3\n"
+	if stderr.String() != expected {
+		t.Errorf("expected stderr %q, but got %q", expected, stderr.String())
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	p := palette{enabled: true}
+
+	source := `let s = fn(x) { if (x) { "yes" } else { 1.5 } }; @`
+	expected := "\x1b[35mlet\x1b[0m s = \x1b[35mfn\x1b[0m(x) { \x1b[35mif\x1b[0m (x) { \x1b[32m\"yes\"\x1b[0m } " +
+		"\x1b[35melse\x1b[0m { \x1b[36m1.5\x1b[0m } }; \x1b[4;31m@\x1b[0m"
+	if highlighted := p.highlight(source); highlighted != expected {
+		t.Errorf("expected %q, but got %q", expected, highlighted)
+	}
+
+	if highlighted := p.highlight(`"open`); highlighted != "\x1b[32m\"open\x1b[0m" {
+		t.Errorf("expected the unterminated string to be highlighted, but got %q", highlighted)
+	}
+
+	if highlighted := p.highlight("x // note"); highlighted != "x \x1b[90m// note\x1b[0m" {
+		t.Errorf("expected the comment to be highlighted, but got %q", highlighted)
+	}
+
+	if highlighted := (palette{}).highlight(source); highlighted != source {
+		t.Errorf("expected a disabled palette to keep the source, but got %q", highlighted)
+	}
+}
+
+func TestRender(t *testing.T) {
+	long := make([]object.Object, maxRenderedElements+5)
+	for i := range long {
+		long[i] = &object.Integer{Value: i}
+	}
+
+	hash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	for _, key := range []string{"b", "a"} {
+		k := &object.String{Value: key}
+		hash.Pairs[k.HashKey()] = object.HashPair{Key: k, Value: &object.String{Value: strings.Repeat(key, 40)}}
+	}
+
+	tests := []struct {
+		obj      object.Object
+		expected string
+	}{
+		{&object.Integer{Value: 1}, "1"},
+		{&object.Array{Elements: long[:3]}, "[0, 1, 2]"},
+		{
+			&object.Array{Elements: []object.Object{hash, &object.Integer{Value: 1}}},
+			"[\n  {\n    a: " + strings.Repeat("a", 40) + ",\n    b: " + strings.Repeat("b", 40) + ",\n  },\n  1,\n]",
+		},
+	}
+
+	// arrays and hashes that contain themselves
+	cyclic := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}}}
+	cyclic.Elements = append(cyclic.Elements, cyclic)
+	self := &object.String{Value: "self"}
+	cyclicHash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	cyclicHash.Pairs[self.HashKey()] = object.HashPair{Key: self, Value: cyclicHash}
+	longCyclic := &object.Array{Elements: []object.Object{&object.String{Value: strings.Repeat("x", 80)}}}
+	longCyclic.Elements = append(longCyclic.Elements, longCyclic)
+	tests = append(tests, []struct {
+		obj      object.Object
+		expected string
+	}{
+		{cyclic, "[1, [...]]"},
+		{cyclicHash, "{self: {...}}"},
+		{longCyclic, "[\n  " + strings.Repeat("x", 80) + ",\n  [...],\n]"},
+	}...)
+
+	for _, tt := range tests {
+		if rendered := render(tt.obj); rendered != tt.expected {
+			t.Errorf("expected %q, but got %q", tt.expected, rendered)
+		}
+	}
+
+	rendered := render(&object.Array{Elements: long})
+	lines := strings.Split(rendered, "\n")
+	if len(lines) != maxRenderedElements+3 || lines[len(lines)-2] != "  ... 5 more" {
+		t.Errorf("expected %d rendered elements followed by ... 5 more, but got %q", maxRenderedElements, rendered)
+	}
+}
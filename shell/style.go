@@ -0,0 +1,104 @@
+package shell
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chzyer/readline"
+
+	"github.com/HakanSunay/gohil/lexer"
+	"github.com/HakanSunay/gohil/token"
+)
+
+// style is an ANSI escape sequence (SGR) that changes the colour of the text after it
+type style string
+
+const (
+	styleReset   style = "\x1b[0m"
+	styleKeyword style = "\x1b[35m"
+	styleString  style = "\x1b[32m"
+	styleNumber  style = "\x1b[36m"
+	styleComment style = "\x1b[90m"
+	styleIllegal style = "\x1b[4;31m"
+	styleError   style = "\x1b[1;31m"
+)
+
+// palette styles text, a disabled palette leaves the text as it is
+type palette struct {
+	enabled bool
+}
+
+// newPalette enables colours only for terminals, the convention of NO_COLOR and TERM=dumb disables them
+func newPalette(writer io.Writer) palette {
+	file, ok := writer.(*os.File)
+	if !ok || !readline.IsTerminal(int(file.Fd())) {
+		return palette{}
+	}
+
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return palette{enabled: !noColor && os.Getenv("TERM") != "dumb"}
+}
+
+func (p palette) paint(s style, text string) string {
+	if !p.enabled || s == "" || text == "" {
+		return text
+	}
+
+	return string(s) + text + string(styleReset)
+}
+
+// highlight colours the source using the types of its tokens, the text between the tokens is kept as it is
+func (p palette) highlight(source string) string {
+	if !p.enabled {
+		return source
+	}
+
+	var builder strings.Builder
+	last := 0
+
+	l := lexer.NewLexer(source, lexer.WithComments())
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		style := tokenStyle(tok)
+		if tok.Type == token.Illegal && l.Unterminated() {
+			// the string or the comment is still being typed
+			style = styleString
+			if strings.HasPrefix(tok.Literal, "/*") {
+				style = styleComment
+			}
+		}
+
+		builder.WriteString(source[last:tok.Pos.Offset])
+		builder.WriteString(p.paint(style, source[tok.Pos.Offset:tok.End.Offset]))
+		last = tok.End.Offset
+	}
+	builder.WriteString(source[last:])
+
+	return builder.String()
+}
+
+func tokenStyle(tok token.Token) style {
+	switch tok.Type {
+	case token.Identifier:
+		return ""
+	case token.String:
+		return styleString
+	case token.Int, token.Float:
+		return styleNumber
+	case token.Comment:
+		return styleComment
+	case token.Illegal:
+		return styleIllegal
+	}
+
+	if token.ParseIdentifier(tok.Literal) == tok.Type {
+		return styleKeyword
+	}
+
+	return ""
+}
+
+// Paint implements readline.Painter, the input is highlighted while it is typed
+func (p palette) Paint(line []rune, _ int) []rune {
+	return []rune(p.highlight(string(line)))
+}
@@ -2,6 +2,8 @@ package syntaxtree
 
 import (
 	"strings"
+
+	"github.com/HakanSunay/gohil/token"
 )
 
 type Program struct {
@@ -25,3 +27,19 @@ func (p *Program) String() string {
 
 	return builder.String()
 }
+
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+
+	return token.Position{}
+}
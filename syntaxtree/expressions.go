@@ -1,6 +1,7 @@
 package syntaxtree
 
 import (
+	"math/big"
 	"strings"
 
 	"github.com/HakanSunay/gohil/token"
@@ -26,9 +27,18 @@ func (i *Identifier) String() string {
 // we will have functions that produce values, which will be assigned to identifiers.
 func (i *Identifier) exprNode() {}
 
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+
+func (i *Identifier) End() token.Position {
+	return i.Token.End
+}
+
 type IntegerLiteral struct {
 	Token token.Token
 	Value int
+	Big   *big.Int // set instead of Value, when the literal does not fit in an int
 }
 
 func (il *IntegerLiteral) GetTokenLiteral() string {
@@ -41,6 +51,37 @@ func (il *IntegerLiteral) String() string {
 
 func (il *IntegerLiteral) exprNode() {}
 
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
+
+func (il *IntegerLiteral) End() token.Position {
+	return il.Token.End
+}
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) GetTokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) exprNode() {}
+
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
+func (fl *FloatLiteral) End() token.Position {
+	return fl.Token.End
+}
+
 type BooleanLiteral struct {
 	Token token.Token
 	Value bool
@@ -56,6 +97,14 @@ func (b *BooleanLiteral) String() string {
 
 func (b *BooleanLiteral) exprNode() {}
 
+func (b *BooleanLiteral) Pos() token.Position {
+	return b.Token.Pos
+}
+
+func (b *BooleanLiteral) End() token.Position {
+	return b.Token.End
+}
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -71,6 +120,14 @@ func (sl *StringLiteral) String() string {
 
 func (sl *StringLiteral) exprNode() {}
 
+func (sl *StringLiteral) Pos() token.Position {
+	return sl.Token.Pos
+}
+
+func (sl *StringLiteral) End() token.Position {
+	return sl.Token.End
+}
+
 // PrefixExpr describes prefix expressions in gohil.
 // There are 2 types of prefix expressions in the language: ! and -
 // E.g: -66
@@ -102,6 +159,14 @@ func (p *PrefixExpr) String() string {
 
 func (p *PrefixExpr) exprNode() {}
 
+func (p *PrefixExpr) Pos() token.Position {
+	return p.Token.Pos
+}
+
+func (p *PrefixExpr) End() token.Position {
+	return endOf(p.Right, p.Token)
+}
+
 // InfixExpr describes infix expressions.
 // There are many infix expressions supported by gohil.
 // All of the arithmetic operations are considered infix expressions.
@@ -141,6 +206,62 @@ func (i *InfixExpr) String() string {
 
 func (i *InfixExpr) exprNode() {}
 
+func (i *InfixExpr) Pos() token.Position {
+	return posOf(i.Left, i.Token)
+}
+
+func (i *InfixExpr) End() token.Position {
+	return endOf(i.Right, i.Token)
+}
+
+// AssignExpr updates the nearest binding of an identifier, or an element of an array or a hash,
+// its value is the assigned value.
+// E.g: x = 6, x += 1, arr[0] = 6, h["key"] -= 1
+// Compound operators (+=, -=, *=, /=) apply the operator to the current and the new value.
+type AssignExpr struct {
+	Token token.Token // the assignment operator
+
+	Target   Expr // an Identifier or an IndexExpression
+	Operator string
+	Value    Expr
+}
+
+func (a *AssignExpr) GetTokenLiteral() string {
+	return a.Token.Literal
+}
+
+func (a *AssignExpr) String() string {
+	var builder strings.Builder
+
+	builder.WriteString("(")
+	builder.WriteString(a.Target.String())
+
+	builder.WriteString(" ")
+	builder.WriteString(a.Operator)
+	builder.WriteString(" ")
+
+	builder.WriteString(a.Value.String())
+	builder.WriteString(")")
+
+	return builder.String()
+}
+
+func (a *AssignExpr) exprNode() {}
+
+func (a *AssignExpr) Pos() token.Position {
+	return posOf(a.Target, a.Token)
+}
+
+func (a *AssignExpr) End() token.Position {
+	return endOf(a.Value, a.Token)
+}
+
+// InfixOperator returns the operator that a compound assignment applies, e.g. + for +=,
+// it is empty for a plain assignment
+func (a *AssignExpr) InfixOperator() string {
+	return strings.TrimSuffix(a.Operator, "=")
+}
+
 type IfExpr struct {
 	Token       token.Token // if
 	Condition   Expr
@@ -170,6 +291,22 @@ func (ie *IfExpr) String() string {
 
 func (ie *IfExpr) exprNode() {}
 
+func (ie *IfExpr) Pos() token.Position {
+	return ie.Token.Pos
+}
+
+func (ie *IfExpr) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+
+	if ie.Consequence != nil {
+		return ie.Consequence.End()
+	}
+
+	return ie.Token.End
+}
+
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
@@ -204,6 +341,18 @@ func (f *FunctionLiteral) GetTokenLiteral() string {
 
 func (f *FunctionLiteral) exprNode() {}
 
+func (f *FunctionLiteral) Pos() token.Position {
+	return f.Token.Pos
+}
+
+func (f *FunctionLiteral) End() token.Position {
+	if f.Body != nil {
+		return f.Body.End()
+	}
+
+	return f.Token.End
+}
+
 // CallExpr identifies a callable expression.
 // call expressions are of this structure:
 // <expression>(<comma separated expressions>)
@@ -214,6 +363,7 @@ type CallExpr struct {
 	Token     token.Token // '(' left parenthesis
 	Function  Expr        // either an identifier or a function literal
 	Arguments []Expr
+	Closing   token.Token // ')' right parenthesis
 }
 
 func (c *CallExpr) String() string {
@@ -238,9 +388,18 @@ func (c *CallExpr) GetTokenLiteral() string {
 
 func (c *CallExpr) exprNode() {}
 
+func (c *CallExpr) Pos() token.Position {
+	return posOf(c.Function, c.Token)
+}
+
+func (c *CallExpr) End() token.Position {
+	return closingEnd(c.Closing, c.Token)
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expr
+	Closing  token.Token // the ']' token
 }
 
 func (al *ArrayLiteral) String() string {
@@ -264,10 +423,20 @@ func (al *ArrayLiteral) GetTokenLiteral() string {
 
 func (al *ArrayLiteral) exprNode() {}
 
+func (al *ArrayLiteral) Pos() token.Position {
+	return al.Token.Pos
+}
+
+func (al *ArrayLiteral) End() token.Position {
+	return closingEnd(al.Closing, al.Token)
+}
+
 type IndexExpression struct {
 	Token token.Token // The [ token
 	Left  Expr        // the left side of an index expression is an expr: arr[4]
 	Index Expr        // the index is also an expression arr[3+4] is valid syntax in gohil
+
+	Closing token.Token // The ] token
 }
 
 func (ie *IndexExpression) String() string {
@@ -289,9 +458,18 @@ func (ie *IndexExpression) GetTokenLiteral() string {
 
 func (ie *IndexExpression) exprNode() {}
 
+func (ie *IndexExpression) Pos() token.Position {
+	return posOf(ie.Left, ie.Token)
+}
+
+func (ie *IndexExpression) End() token.Position {
+	return closingEnd(ie.Closing, ie.Token)
+}
+
 type HashLiteral struct {
-	Token token.Token // the '{' token
-	Pairs map[Expr]Expr
+	Token   token.Token // the '{' token
+	Pairs   map[Expr]Expr
+	Closing token.Token // the '}' token
 }
 
 func (hl *HashLiteral) String() string {
@@ -314,3 +492,21 @@ func (hl *HashLiteral) GetTokenLiteral() string {
 }
 
 func (hl *HashLiteral) exprNode() {}
+
+func (hl *HashLiteral) Pos() token.Position {
+	return hl.Token.Pos
+}
+
+func (hl *HashLiteral) End() token.Position {
+	return closingEnd(hl.Closing, hl.Token)
+}
+
+// closingEnd returns the end of the closing token of a node,
+// if the closing token is missing (e.g. due to a parse error), the end of the opening token is used.
+func closingEnd(closing token.Token, opening token.Token) token.Position {
+	if closing.End.IsValid() {
+		return closing.End
+	}
+
+	return opening.End
+}
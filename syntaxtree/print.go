@@ -0,0 +1,95 @@
+package syntaxtree
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/HakanSunay/gohil/token"
+)
+
+var (
+	nodeType  = reflect.TypeOf((*Node)(nil)).Elem()
+	tokenType = reflect.TypeOf(token.Token{})
+)
+
+// Fprint writes the tree rooted at node to the writer, one node or value per line.
+// Children are indented under their parent and labeled with the name of the field
+// that holds them, tokens are left out since their literals are part of the nodes.
+//
+//	LetStmt 1:1
+//	  Name: Identifier 1:5
+//	    Value: "x"
+func Fprint(writer io.Writer, node Node) error {
+	p := printer{writer: writer}
+	p.node("", node, 0)
+
+	return p.err
+}
+
+// printer keeps the first error that occurred while writing, so that the walk does not have to
+type printer struct {
+	writer io.Writer
+	err    error
+}
+
+func (p *printer) printf(depth int, format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+
+	_, p.err = fmt.Fprintf(p.writer, strings.Repeat("  ", depth)+format+"\n", args...)
+}
+
+func (p *printer) node(label string, node Node, depth int) {
+	value := reflect.ValueOf(node)
+	if node == nil || value.Kind() == reflect.Ptr && value.IsNil() {
+		return
+	}
+
+	value = reflect.Indirect(value)
+	p.printf(depth, "%s%s %s", label, value.Type().Name(), node.Pos())
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		// tokens only repeat what the nodes already say
+		if field.PkgPath != "" || field.Type == tokenType {
+			continue
+		}
+
+		p.value(field.Name, value.Field(i), depth+1)
+	}
+}
+
+func (p *printer) value(label string, value reflect.Value, depth int) {
+	switch {
+	case value.Type().Implements(nodeType):
+		if !value.IsNil() {
+			p.node(label+": ", value.Interface().(Node), depth)
+		}
+	case value.Kind() == reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			p.value(fmt.Sprintf("%s[%d]", label, i), value.Index(i), depth)
+		}
+	case value.Kind() == reflect.Map:
+		// the pairs are printed in the order of their source, to keep the output stable
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].Interface().(Node).Pos().Offset < keys[j].Interface().(Node).Pos().Offset
+		})
+		for i, key := range keys {
+			p.value(fmt.Sprintf("%s[%d].Key", label, i), key, depth)
+			p.value(fmt.Sprintf("%s[%d].Value", label, i), value.MapIndex(key), depth)
+		}
+	case value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface:
+		if !value.IsNil() {
+			p.printf(depth, "%s: %v", label, value.Interface())
+		}
+	case value.Kind() == reflect.String:
+		p.printf(depth, "%s: %q", label, value.String())
+	default:
+		p.printf(depth, "%s: %v", label, value.Interface())
+	}
+}
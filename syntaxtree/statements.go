@@ -11,10 +11,13 @@ import (
 // This means that we need a token that identifies this statement - token.Let.
 // We need an identifier - x.
 // We also need a value - 6.
+// Doc is the text of the comments right before the statement, without their delimiters.
+// Const statements (const x = 6) are LetStmts with a token.Const token.
 type LetStmt struct {
 	Token token.Token
 	Name  *Identifier
 	Value Expr
+	Doc   string
 }
 
 func (l *LetStmt) String() string {
@@ -34,12 +37,25 @@ func (l *LetStmt) String() string {
 	return builder.String()
 }
 
+// Constant reports whether the statement is a const statement, whose binding cannot be changed
+func (l *LetStmt) Constant() bool {
+	return l.Token.Type == token.Const
+}
+
 func (l *LetStmt) GetTokenLiteral() string {
 	return l.Token.Literal
 }
 
 func (l *LetStmt) stmtNode() {}
 
+func (l *LetStmt) Pos() token.Position {
+	return l.Token.Pos
+}
+
+func (l *LetStmt) End() token.Position {
+	return endOf(l.Value, l.Token)
+}
+
 // ReturnStmt defines a return statement.
 // E.g: return 6; return keyword and expression.
 // This means that we need a token that identifies this statement - token.Return.
@@ -55,6 +71,14 @@ func (r *ReturnStmt) GetTokenLiteral() string {
 
 func (r *ReturnStmt) stmtNode() {}
 
+func (r *ReturnStmt) Pos() token.Position {
+	return r.Token.Pos
+}
+
+func (r *ReturnStmt) End() token.Position {
+	return endOf(r.ReturnValue, r.Token)
+}
+
 func (r *ReturnStmt) String() string {
 	var builder strings.Builder
 
@@ -98,11 +122,20 @@ func (e *ExpressionStmt) String() string {
 
 func (e *ExpressionStmt) stmtNode() {}
 
+func (e *ExpressionStmt) Pos() token.Position {
+	return posOf(e.Expression, e.Token)
+}
+
+func (e *ExpressionStmt) End() token.Position {
+	return endOf(e.Expression, e.Token)
+}
+
 // BlockStmt defines a block statement.
 // Used in conditional expressions - if, and function definitions
 type BlockStmt struct {
 	Token      token.Token // the { token
 	Statements []Stmt
+	Closing    token.Token // the } token
 }
 
 func (bs *BlockStmt) GetTokenLiteral() string {
@@ -119,3 +152,114 @@ func (bs *BlockStmt) String() string {
 }
 
 func (bs *BlockStmt) stmtNode() {}
+
+func (bs *BlockStmt) Pos() token.Position {
+	return bs.Token.Pos
+}
+
+func (bs *BlockStmt) End() token.Position {
+	return closingEnd(bs.Closing, bs.Token)
+}
+
+// WhileStmt defines a while loop, which evaluates its body as long as its condition is truthy.
+// E.g: while (x < 10) { let x = x + 1; }
+type WhileStmt struct {
+	Token     token.Token // while
+	Condition Expr
+	Body      *BlockStmt
+}
+
+func (w *WhileStmt) GetTokenLiteral() string {
+	return w.Token.Literal
+}
+
+func (w *WhileStmt) String() string {
+	var builder strings.Builder
+
+	builder.WriteString("while")
+	builder.WriteString(w.Condition.String())
+	builder.WriteString(" ")
+	builder.WriteString(w.Body.String())
+
+	return builder.String()
+}
+
+func (w *WhileStmt) stmtNode() {}
+
+func (w *WhileStmt) Pos() token.Position {
+	return w.Token.Pos
+}
+
+func (w *WhileStmt) End() token.Position {
+	if w.Body != nil {
+		return w.Body.End()
+	}
+
+	return w.Token.End
+}
+
+// ForStmt defines a for loop, which binds every element of the iterable to the variable
+// and evaluates its body for it. Arrays are iterated by their elements, hashes by their keys
+// and strings by their characters.
+// E.g: for (x in [1, 2, 3]) { puts(x); }
+type ForStmt struct {
+	Token    token.Token // for
+	Variable *Identifier
+	Iterable Expr
+	Body     *BlockStmt
+}
+
+func (f *ForStmt) GetTokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *ForStmt) String() string {
+	var builder strings.Builder
+
+	builder.WriteString("for (")
+	builder.WriteString(f.Variable.String())
+	builder.WriteString(" in ")
+	builder.WriteString(f.Iterable.String())
+	builder.WriteString(") ")
+	builder.WriteString(f.Body.String())
+
+	return builder.String()
+}
+
+func (f *ForStmt) stmtNode() {}
+
+func (f *ForStmt) Pos() token.Position {
+	return f.Token.Pos
+}
+
+func (f *ForStmt) End() token.Position {
+	if f.Body != nil {
+		return f.Body.End()
+	}
+
+	return f.Token.End
+}
+
+// BranchStmt defines a break or a continue statement, which is only allowed inside of a loop.
+// break ends the loop, continue skips the rest of its body and goes on with the next iteration.
+type BranchStmt struct {
+	Token token.Token // break or continue
+}
+
+func (b *BranchStmt) GetTokenLiteral() string {
+	return b.Token.Literal
+}
+
+func (b *BranchStmt) String() string {
+	return b.Token.Literal + ";"
+}
+
+func (b *BranchStmt) stmtNode() {}
+
+func (b *BranchStmt) Pos() token.Position {
+	return b.Token.Pos
+}
+
+func (b *BranchStmt) End() token.Position {
+	return b.Token.End
+}
@@ -1,12 +1,42 @@
 package syntaxtree
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/HakanSunay/gohil/token"
+)
 
 // Node is an interface that must be implemented by every node in the tree
 type Node interface {
 	fmt.Stringer
 
 	GetTokenLiteral() string
+
+	// Pos returns the position of the first character that belongs to the node,
+	// End returns the position immediately after the node.
+	// Together they describe the span of the node in the source text.
+	Pos() token.Position
+	End() token.Position
+}
+
+// endOf returns the end of the given node,
+// if the node is missing (e.g. due to a parse error), the end of the fallback token is used.
+func endOf(node Node, fallback token.Token) token.Position {
+	if node == nil {
+		return fallback.End
+	}
+
+	return node.End()
+}
+
+// posOf returns the start of the given node,
+// if the node is missing (e.g. due to a parse error), the start of the fallback token is used.
+func posOf(node Node, fallback token.Token) token.Position {
+	if node == nil {
+		return fallback.Pos
+	}
+
+	return node.Pos()
 }
 
 // Statement is a type of node which provides statement functionality.
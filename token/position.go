@@ -0,0 +1,25 @@
+package token
+
+import "fmt"
+
+// Position describes a location in the source text.
+// Offset is the byte offset starting at 0,
+// Line and Column are starting at 1, which is what editors and humans expect.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position has been set, the zero value is not a valid position
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
@@ -4,10 +4,15 @@ package token
 type Token struct {
 	Type    Type
 	Literal string
+
+	// Pos is the position of the first character of the token,
+	// End is the position immediately after the last character of the token.
+	Pos Position
+	End Position
 }
 
 // Set sets the fields of the token type
-func (t *Token) Set(typ Type, literal byte) {
+func (t *Token) Set(typ Type, literal rune) {
 	t.Type = typ
 	t.Literal = string(literal)
 }
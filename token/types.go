@@ -1,5 +1,7 @@
 package token
 
+import "sort"
+
 // Type identifies the token type and is just an alias to string type.
 // String type is easier to work with when debugging,
 // but in the ideal case scenario int or byte should be used for performance.
@@ -21,19 +23,32 @@ const (
 
 	// Primitive types
 	Int    = Type("Int")
+	Float  = Type("Float")
 	String = Type("String")
 
+	// Comment is only produced by lexers that emit comments, e.g. // line or /* block */
+	Comment = Type("Comment")
+
 	// Operators
 	Assign          = Type("=")
+	PlusAssign      = Type("+=")
+	MinusAssign     = Type("-=")
+	AsteriskAssign  = Type("*=")
+	SlashAssign     = Type("/=")
 	Plus            = Type("+")
 	Minus           = Type("-")
 	ExclamationMark = Type("!")
 	Asterisk        = Type("*")
 	Slash           = Type("/")
+	Percent         = Type("%")
 	LessThan        = Type("<")
 	GreaterThan     = Type(">")
+	LessEqual       = Type("<=")
+	GreaterEqual    = Type(">=")
 	Equal           = Type("==")
 	NotEqual        = Type("!=")
+	And             = Type("&&")
+	Or              = Type("||")
 
 	// Delimiters
 	Comma            = Type(",")
@@ -49,22 +64,45 @@ const (
 	// Keywords
 	Function = Type("Function")
 	Let      = Type("Let")
+	Const    = Type("Const")
 	True     = Type("True")
 	False    = Type("False")
 	If       = Type("If")
 	Else     = Type("Else")
 	Return   = Type("Return")
+	While    = Type("While")
+	For      = Type("For")
+	In       = Type("In")
+	Break    = Type("Break")
+	Continue = Type("Continue")
 )
 
 // keywords that are supported by gohil
 var keywords = map[string]Type{
-	"fn":     Function,
-	"let":    Let,
-	"true":   True,
-	"false":  False,
-	"if":     If,
-	"else":   Else,
-	"return": Return,
+	"fn":       Function,
+	"let":      Let,
+	"const":    Const,
+	"true":     True,
+	"false":    False,
+	"if":       If,
+	"else":     Else,
+	"return":   Return,
+	"while":    While,
+	"for":      For,
+	"in":       In,
+	"break":    Break,
+	"continue": Continue,
+}
+
+// Keywords returns the sorted keywords of gohil
+func Keywords() []string {
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
 }
 
 // ParseIdentifier is used to parse a string to a token type.
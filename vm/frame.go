@@ -0,0 +1,25 @@
+package vm
+
+import (
+	"github.com/HakanSunay/gohil/code"
+	"github.com/HakanSunay/gohil/object"
+)
+
+// Frame is the call frame of a closure that is being executed
+type Frame struct {
+	cl *object.Closure
+	// ip points to the instruction that is being executed
+	ip int
+	// basePointer is the stack pointer before the call, the locals of the frame start there
+	basePointer int
+}
+
+// NewFrame is the constructor for the Frame type
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+// Instructions returns the instructions of the executed function
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
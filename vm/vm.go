@@ -0,0 +1,609 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/HakanSunay/gohil/code"
+	"github.com/HakanSunay/gohil/compiler"
+	"github.com/HakanSunay/gohil/eval"
+	"github.com/HakanSunay/gohil/object"
+	"github.com/HakanSunay/gohil/token"
+)
+
+// StackSize is the maximum number of values on the stack
+const StackSize = 1 << 16
+
+// anonymousFunctionName is used in the call stack for functions that were not bound with let
+const anonymousFunctionName = "<anonymous>"
+
+// infixOperators maps the infix instructions to the operators that are applied by eval.Infix
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpLessThan:     "<",
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+}
+
+// VM executes bytecode produced by the compiler.
+// Globals are stored in the environment, therefore they are shared with the tree-walking evaluator
+// and with the next programs that are run in the same environment.
+type VM struct {
+	environment *object.Environment
+
+	stack []object.Object
+	sp    int // always points to the next free slot, the top of the stack is stack[sp-1]
+
+	frames []*Frame
+
+	// budget bounds the resources of a run, see RunContext
+	budget *eval.Budget
+
+	// lastPopped is the value of the last expression statement, which is the result of the program
+	lastPopped object.Object
+}
+
+// New creates a virtual machine that runs the bytecode in the given environment
+func New(bytecode *compiler.Bytecode, environment *object.Environment) *VM {
+	main := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		SourceMap:    bytecode.SourceMap,
+		Constants:    bytecode.Constants,
+		NumLocals:    bytecode.NumLocals,
+		LocalNames:   bytecode.LocalNames,
+		Name:         object.MainFunctionName,
+	}
+
+	// the locals of the blocks of the program are at the bottom of the stack
+	vm := &VM{environment: environment, stack: make([]object.Object, bytecode.NumLocals), sp: bytecode.NumLocals}
+	vm.frames = append(vm.frames, NewFrame(&object.Closure{Fn: main}, 0))
+	return vm
+}
+
+// Apply calls the given closure, function or builtin with the given arguments, with the default limits.
+// It is used by hosts to call gohil functions, globals are looked up in the given environment.
+func Apply(environment *object.Environment, fn object.Object, args ...object.Object) object.Object {
+	return ApplyContext(context.Background(), eval.Limits{}, environment, fn, args...)
+}
+
+// ApplyContext is Apply with a context and limits, see RunContext
+func ApplyContext(ctx context.Context, limits eval.Limits, environment *object.Environment, fn object.Object, args ...object.Object) object.Object {
+	// a program that consists of the call only, the callee and the arguments are already on the stack
+	var trampoline code.Instructions
+	trampoline = append(trampoline, code.Make(code.OpCall, len(args))...)
+	trampoline = append(trampoline, code.Make(code.OpPop)...)
+
+	vm := New(&compiler.Bytecode{Instructions: trampoline}, environment)
+	vm.stack = make([]object.Object, 0, len(args)+1)
+	vm.stack = append(vm.stack, fn)
+	vm.stack = append(vm.stack, args...)
+	vm.sp = len(vm.stack)
+
+	return vm.RunContext(ctx, limits)
+}
+
+// Run executes the bytecode and returns the value of the program, which is
+// the value of its last expression statement, the value of a top level return or an error.
+// The default limits apply to the execution.
+func (vm *VM) Run() object.Object {
+	return vm.RunContext(context.Background(), eval.Limits{})
+}
+
+// RunContext is Run that ends with an error when the context is done or when the limits are exceeded.
+// Every executed instruction counts as a step.
+func (vm *VM) RunContext(ctx context.Context, limits eval.Limits) (result object.Object) {
+	defer eval.RecoverInternalError(ctx, &result)
+
+	vm.budget = eval.NewBudget(ctx, limits)
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		if err := vm.budget.Step(); err != nil {
+			// the instruction that was about to be executed is the one that is reported
+			vm.currentFrame().ip++
+			return vm.fail(err)
+		}
+
+		frame := vm.currentFrame()
+		frame.ip++
+
+		ip := frame.ip
+		ins := frame.Instructions()
+		op := code.Opcode(ins[ip])
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.push(frame.cl.Fn.Constants[index])
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan, code.OpGreaterEqual, code.OpLessEqual:
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(eval.Infix(infixOperators[op], left, right))
+		case code.OpMinus:
+			err = vm.pushResult(eval.Prefix("-", vm.pop()))
+		case code.OpBang:
+			err = vm.pushResult(eval.Prefix("!", vm.pop()))
+
+		case code.OpTrue:
+			err = vm.push(eval.True)
+		case code.OpFalse:
+			err = vm.push(eval.False)
+		case code.OpNull:
+			err = vm.push(eval.Null)
+
+		case code.OpJump:
+			position := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip = position - 1
+		case code.OpJumpNotTruthy:
+			position := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			if !eval.IsTruthy(vm.pop()) {
+				frame.ip = position - 1
+			}
+		case code.OpJumpFalsyOrPop, code.OpJumpTruthyOrPop:
+			position := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			if eval.IsTruthy(vm.stack[vm.sp-1]) == (op == code.OpJumpTruthyOrPop) {
+				frame.ip = position - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpIterator:
+			values, iterErr := eval.Iterate(vm.pop())
+			if iterErr != nil {
+				err = iterErr
+				break
+			}
+			err = vm.push(&iterator{values: values})
+		case code.OpNext:
+			position := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			it := vm.stack[vm.sp-1].(*iterator)
+			if it.next == len(it.values) {
+				frame.ip = position - 1
+				break
+			}
+			it.next++
+			err = vm.push(it.values[it.next-1])
+		case code.OpEndLoop:
+			count := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			vm.sp -= count
+			// loops have no value
+			vm.lastPopped = nil
+
+		case code.OpGetGlobal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.pushResult(vm.global(vm.constantName(frame, index)))
+		case code.OpSetGlobal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.environment.Set(vm.constantName(frame, index), vm.pop())
+			// let statements have no value
+			vm.lastPopped = nil
+		case code.OpSetConstGlobal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.environment.SetConst(vm.constantName(frame, index), vm.pop())
+			vm.lastPopped = nil
+
+		case code.OpAssignGlobal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.environment.Assign(vm.constantName(frame, index), vm.stack[vm.sp-1])
+
+		case code.OpGetLocal:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			value, getErr := vm.getLocal(frame, index)
+			if getErr != nil {
+				err = getErr
+				break
+			}
+			err = vm.push(value)
+		case code.OpSetLocal:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			vm.setLocal(frame, index, vm.pop())
+		case code.OpClearLocals:
+			first := int(code.ReadUint8(ins[ip+1:]))
+			count := int(code.ReadUint8(ins[ip+2:]))
+			frame.ip += 2
+			for i := frame.basePointer + first; i < frame.basePointer+first+count; i++ {
+				vm.stack[i] = nil
+			}
+		case code.OpAssignLocal:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			if _, getErr := vm.getLocal(frame, index); getErr != nil {
+				err = getErr
+				break
+			}
+			vm.setLocal(frame, index, vm.stack[vm.sp-1])
+
+		case code.OpGetFree:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			c := frame.cl.Free[index].(*cell)
+			if c.value == nil {
+				err = notBound(c.name)
+				break
+			}
+			err = vm.push(c.value)
+		case code.OpAssignFree:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			c := frame.cl.Free[index].(*cell)
+			if c.value == nil {
+				err = notBound(c.name)
+				break
+			}
+			c.value = vm.stack[vm.sp-1]
+		case code.OpCaptureLocal:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			err = vm.push(vm.captureLocal(frame, index))
+		case code.OpCaptureFree:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			err = vm.push(frame.cl.Free[index])
+		case code.OpCurrentClosure:
+			err = vm.push(frame.cl)
+
+		case code.OpArray:
+			count := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			err = vm.push(vm.buildArray(count))
+		case code.OpHash:
+			count := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			err = vm.pushResult(vm.buildHash(count))
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(eval.Index(left, index))
+		case code.OpSetIndex:
+			infix := code.Opcode(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			err = vm.pushResult(vm.setIndex(infix))
+
+		case code.OpCall:
+			argCount := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			err = vm.call(argCount)
+		case code.OpReturnValue, code.OpReturn:
+			var returnValue object.Object = eval.Null
+			if op == code.OpReturnValue {
+				returnValue = vm.pop()
+			}
+
+			// a return from the bottom frame ends the program
+			if len(vm.frames) == 1 {
+				return returnValue
+			}
+
+			returned := vm.popFrame()
+			vm.sp = returned.basePointer - 1
+			err = vm.push(returnValue)
+
+		case code.OpClosure:
+			index := code.ReadUint16(ins[ip+1:])
+			freeCount := int(code.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+			err = vm.push(vm.buildClosure(frame, int(index), freeCount))
+
+		case code.OpFail:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = newError("%s", frame.cl.Fn.Constants[index].(*object.String).Value)
+
+		default:
+			err = newError("unknown opcode %d", op)
+		}
+
+		if err != nil {
+			return vm.fail(err)
+		}
+	}
+
+	return vm.lastPopped
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[len(vm.frames)-1]
+}
+
+func (vm *VM) pushFrame(frame *Frame) {
+	vm.frames = append(vm.frames, frame)
+}
+
+func (vm *VM) popFrame() *Frame {
+	frame := vm.currentFrame()
+	vm.frames = vm.frames[:len(vm.frames)-1]
+	return frame
+}
+
+// reserve makes sure that the stack has room for size values
+func (vm *VM) reserve(size int) *object.Error {
+	if size > StackSize {
+		return newError(eval.StackDepthMessage)
+	}
+
+	for len(vm.stack) < size {
+		vm.stack = append(vm.stack, nil)
+	}
+
+	return nil
+}
+
+func (vm *VM) push(obj object.Object) *object.Error {
+	if err := vm.reserve(vm.sp + 1); err != nil {
+		return err
+	}
+
+	vm.stack[vm.sp] = obj
+	vm.sp++
+	return nil
+}
+
+// pushResult pushes the result of an operation, unless the operation failed
+func (vm *VM) pushResult(obj object.Object) *object.Error {
+	if errObj, ok := obj.(*object.Error); ok {
+		return errObj
+	}
+
+	return vm.push(obj)
+}
+
+func (vm *VM) pop() object.Object {
+	obj := vm.stack[vm.sp-1]
+	vm.sp--
+	return obj
+}
+
+func (vm *VM) constantName(frame *Frame, index uint16) string {
+	return frame.cl.Fn.Constants[index].(*object.String).Value
+}
+
+// global looks up the name in the environment, then among the builtins
+func (vm *VM) global(name string) object.Object {
+	if val, ok := vm.environment.Get(name); ok {
+		return val
+	}
+
+	if builtin, ok := eval.LookupBuiltin(name); ok {
+		return builtin
+	}
+
+	return newError("identifier not found: " + name)
+}
+
+// getLocal returns the value of the local from its slot, or from its cell once it is captured
+func (vm *VM) getLocal(frame *Frame, index int) (object.Object, *object.Error) {
+	value := vm.stack[frame.basePointer+index]
+	if c, ok := value.(*cell); ok {
+		value = c.value
+	}
+
+	// the slot is empty until the let statement of the local runs, it may have been skipped by a branch
+	if value == nil {
+		return nil, notBound(frame.cl.Fn.LocalNames[index])
+	}
+
+	return value, nil
+}
+
+// notBound is the error about a local that is not bound yet, it is the error of eval about an unknown name
+func notBound(name string) *object.Error {
+	return newError("identifier not found: %s", name)
+}
+
+// setLocal stores the value in the slot of the local, or in its cell once it is captured
+func (vm *VM) setLocal(frame *Frame, index int, value object.Object) {
+	slot := &vm.stack[frame.basePointer+index]
+	if c, ok := (*slot).(*cell); ok {
+		c.value = value
+		return
+	}
+
+	*slot = value
+}
+
+// captureLocal moves the value of the local into a cell, which is shared with the closures that capture it
+func (vm *VM) captureLocal(frame *Frame, index int) *cell {
+	slot := &vm.stack[frame.basePointer+index]
+	if c, ok := (*slot).(*cell); ok {
+		return c
+	}
+
+	c := &cell{name: frame.cl.Fn.LocalNames[index], value: *slot}
+	*slot = c
+	return c
+}
+
+// setIndex pops the value, the index and the indexed object of an indexed assignment,
+// infix is the instruction of a compound assignment or 0
+func (vm *VM) setIndex(infix code.Opcode) object.Object {
+	value := vm.pop()
+	index := vm.pop()
+	left := vm.pop()
+
+	if infix != 0 {
+		current := eval.Index(left, index)
+		if _, ok := current.(*object.Error); ok {
+			return current
+		}
+
+		value = eval.Infix(infixOperators[infix], current, value)
+		if _, ok := value.(*object.Error); ok {
+			return value
+		}
+	}
+
+	return eval.SetIndex(left, index, value)
+}
+
+func (vm *VM) buildArray(count int) object.Object {
+	elements := make([]object.Object, count)
+	copy(elements, vm.stack[vm.sp-count:vm.sp])
+	vm.sp -= count
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(count int) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+	for i := vm.sp - count; i < vm.sp; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	vm.sp -= count
+
+	return &object.Hash{Pairs: pairs}
+}
+
+func (vm *VM) buildClosure(frame *Frame, index int, freeCount int) object.Object {
+	fn := frame.cl.Fn.Constants[index].(*object.CompiledFunction)
+
+	free := make([]object.Object, freeCount)
+	for i, value := range vm.stack[vm.sp-freeCount : vm.sp] {
+		// the closure itself is captured by value (OpCurrentClosure), everything else in a cell
+		if _, ok := value.(*cell); !ok {
+			value = &cell{value: value}
+		}
+		free[i] = value
+	}
+	vm.sp -= freeCount
+
+	return &object.Closure{Fn: fn, Free: free}
+}
+
+// iterator is pushed by OpIterator, it holds the values of a for loop and the index of the next one
+type iterator struct {
+	values []object.Object
+	next   int
+}
+
+func (it *iterator) Type() object.Type {
+	return "Iterator"
+}
+
+func (it *iterator) Inspect() string {
+	return fmt.Sprintf("iterator(%d/%d)", it.next, len(it.values))
+}
+
+// cell holds a local variable that is captured by closures, it takes the place of the value
+// on the stack of the enclosing function and in the free variables of the closures
+type cell struct {
+	name  string
+	value object.Object
+}
+
+func (c *cell) Type() object.Type {
+	return "Cell"
+}
+
+func (c *cell) Inspect() string {
+	return "cell"
+}
+
+// call calls the function below the given number of arguments on the stack
+func (vm *VM) call(argCount int) *object.Error {
+	callee := vm.stack[vm.sp-1-argCount]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		if argCount != callee.Fn.NumParameters {
+			return newError("wrong number of arguments. got=%d, want=%d", argCount, callee.Fn.NumParameters)
+		}
+		// the bottom frame is not a function call
+		if err := vm.budget.EnterCall(len(vm.frames) - 1); err != nil {
+			return err
+		}
+
+		// the arguments become the first locals of the new frame
+		frame := NewFrame(callee, vm.sp-argCount)
+		if err := vm.reserve(frame.basePointer + callee.Fn.NumLocals); err != nil {
+			return err
+		}
+
+		// the slots of the other locals may still hold the cells of a previous call
+		for i := vm.sp; i < frame.basePointer+callee.Fn.NumLocals; i++ {
+			vm.stack[i] = nil
+		}
+
+		vm.pushFrame(frame)
+		vm.sp = frame.basePointer + callee.Fn.NumLocals
+		return nil
+	case *object.Builtin, *object.Function:
+		args := make([]object.Object, argCount)
+		copy(args, vm.stack[vm.sp-argCount:vm.sp])
+		vm.sp -= argCount + 1
+
+		// functions created by the evaluator (e.g. by a host) are evaluated by it, within the budget of the run
+		result := eval.ApplyBudget(vm.budget, len(vm.frames)-1, anonymousFunctionName, callee, args...)
+		if result == nil {
+			result = eval.Null
+		}
+		return vm.pushResult(result)
+	default:
+		return newError("not a function: %s", callee.Type())
+	}
+}
+
+// fail stamps the error with the position of the failed instruction and the call stack
+func (vm *VM) fail(err *object.Error) object.Object {
+	if !err.Pos.IsValid() {
+		err.Pos = vm.position(vm.currentFrame())
+		err.Stack = vm.callStack()
+	}
+
+	return err
+}
+
+// position returns the source position of the instruction the frame is executing
+func (vm *VM) position(frame *Frame) token.Position {
+	return frame.cl.Fn.SourceMap.Lookup(frame.ip)
+}
+
+// callStack returns the function calls that are being executed, the bottom frame excluded
+func (vm *VM) callStack() []object.Frame {
+	stack := make([]object.Frame, 0, len(vm.frames)-1)
+	for i := 1; i < len(vm.frames); i++ {
+		name := vm.frames[i].cl.Fn.Name
+		if name == "" {
+			name = anonymousFunctionName
+		}
+
+		// the caller is still executing the call instruction
+		stack = append(stack, object.Frame{Function: name, Pos: vm.position(vm.frames[i-1])})
+	}
+
+	return stack
+}
+
+func newError(format string, args ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,157 @@
+package vm
+
+import (
+	"context"
+
+	"testing"
+
+	"github.com/HakanSunay/gohil/compiler"
+	"github.com/HakanSunay/gohil/eval"
+	"github.com/HakanSunay/gohil/lexer"
+	"github.com/HakanSunay/gohil/object"
+	"github.com/HakanSunay/gohil/parser"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"1 + 2 * 3", 7},
+		{"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(15)", 610},
+		{"let adder = fn(a) { fn(b) { fn(c) { a + b + c } } }; adder(1)(2)(3)", 6},
+		{"let twice = fn(f, x) { f(f(x)) }; twice(fn(x) { x * 2 }, 3)", 12},
+		{"let f = fn() { let loop = fn(n) { if (n > 0) { loop(n - 1) } else { 42 } }; loop(10) }; f()", 42},
+		{"return 5; 6", 5},
+		{`len("four") + len([1, 2])`, 6},
+	}
+
+	for _, tt := range tests {
+		result := run(t, tt.input, object.NewEnvironment())
+		integer, ok := result.(*object.Integer)
+		if !ok {
+			t.Errorf("%q: expected Integer, but got %T (%+v)", tt.input, result, result)
+			continue
+		}
+		if integer.Value != tt.expected {
+			t.Errorf("%q: expected %d, but got %d", tt.input, tt.expected, integer.Value)
+		}
+	}
+}
+
+func TestGlobalsAreSharedThroughTheEnvironment(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("base", &object.Integer{Value: 10})
+
+	run(t, "let add = fn(x) { x + base };", env)
+	result := run(t, "add(5)", env)
+
+	if integer, ok := result.(*object.Integer); !ok || integer.Value != 15 {
+		t.Errorf("expected 15, but got %+v", result)
+	}
+}
+
+func TestStackDepthExceeded(t *testing.T) {
+	result := run(t, "let f = fn(n) { f(n + 1) }; f(0)", object.NewEnvironment())
+
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("expected an Error, but got %T", result)
+	}
+	if errObj.Message != "stack depth exceeded" {
+		t.Errorf("unexpected error message %q", errObj.Message)
+	}
+	if len(errObj.Stack) != eval.DefaultMaxDepth {
+		t.Errorf("expected %d frames, but got %d", eval.DefaultMaxDepth, len(errObj.Stack))
+	}
+}
+
+func TestApply(t *testing.T) {
+	env := object.NewEnvironment()
+	run(t, "let mul = fn(a, b) { a * b }; let broken = fn() { missing };", env)
+
+	mul, _ := env.Get("mul")
+	result := Apply(env, mul, &object.Integer{Value: 6}, &object.Integer{Value: 7})
+	if integer, ok := result.(*object.Integer); !ok || integer.Value != 42 {
+		t.Errorf("expected 42, but got %+v", result)
+	}
+
+	length, _ := eval.LookupBuiltin("len")
+	result = Apply(env, length, &object.String{Value: "gohil"})
+	if integer, ok := result.(*object.Integer); !ok || integer.Value != 5 {
+		t.Errorf("expected 5, but got %+v", result)
+	}
+
+	broken, _ := env.Get("broken")
+	result = Apply(env, broken)
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("expected an Error, but got %T", result)
+	}
+	if errObj.Pos.String() != "1:51" || len(errObj.Stack) != 1 || errObj.Stack[0].Function != "broken" {
+		t.Errorf("unexpected error %q at %s with stack %+v", errObj.Message, errObj.Pos, errObj.Stack)
+	}
+}
+
+func run(t *testing.T, input string, env *object.Environment) object.Object {
+	t.Helper()
+
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if errs := p.GetErrors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	c := compiler.NewCompiler()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+
+	return New(c.Bytecode(), env).Run()
+}
+
+func TestCallsToTheEvaluatorShareTheBudget(t *testing.T) {
+	tests := []struct {
+		evaluated string
+		input     string
+		limits    eval.Limits
+		expected  string
+	}{
+		// every call of count takes less than MaxSteps, but not the two of them
+		{
+			"let count = fn(n) { let i = 0; while (i < n) { i = i + 1 }; i };",
+			"let twice = fn(f, n) { f(n) + f(n) }; twice(count, 50)",
+			eval.Limits{MaxSteps: 600},
+			eval.StepLimitMessage,
+		},
+		// neither of the recursions goes deeper than MaxDepth, but together they do
+		{
+			"let down = fn(n) { if (n > 0) { down(n - 1) } else { 0 } };",
+			"let f = fn(n) { if (n > 0) { f(n - 1) } else { down(8) } }; f(8)",
+			eval.Limits{MaxDepth: 12},
+			eval.StackDepthMessage,
+		},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		// the functions defined by the evaluator are called by the VM through the evaluator
+		eval.Eval(parser.NewParser(lexer.NewLexer(tt.evaluated)).ParseProgram(), env)
+
+		p := parser.NewParser(lexer.NewLexer(tt.input))
+		c := compiler.NewCompiler()
+		if err := c.Compile(p.ParseProgram()); err != nil {
+			t.Fatalf("compilation failed: %v", err)
+		}
+
+		result := New(c.Bytecode(), env).RunContext(context.Background(), tt.limits)
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an Error, but got %s", tt.input, result.Inspect())
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%q: expected %q, but got %q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}